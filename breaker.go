@@ -0,0 +1,161 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultBreakerCooldown is how long crawling of an origin is paused when its
+// circuit breaker opens, before a single request probes whether it recovered.
+const DefaultBreakerCooldown = time.Second * 30
+
+// DefaultBreakerDeadline is how long an origin can keep failing once its
+// circuit breaker opened before the crawl is aborted.
+const DefaultBreakerDeadline = time.Minute * 5
+
+// OriginDownError is returned by a crawl that was aborted because an origin
+// kept failing past the circuit breaker deadline.
+type OriginDownError struct {
+	Origin    string
+	DownSince time.Time
+	Deadline  time.Duration
+}
+
+func (e *OriginDownError) Error() string {
+	return fmt.Sprintf(
+		"crawl aborted: %s has been failing since %s, longer than %s",
+		e.Origin,
+		e.DownSince.Format(time.RFC3339),
+		e.Deadline,
+	)
+}
+
+// breakerEvent is a change in the state of a circuit breaker.
+type breakerEvent int
+
+const (
+	breakerUnchanged breakerEvent = iota
+	breakerOpened
+	breakerClosed
+)
+
+// circuitBreaker stops the crawl of an origin that keeps failing. The breaker
+// opens after threshold consecutive failed requests and nothing is fetched
+// from the origin during the cooldown. After the cooldown a single probe
+// request is allowed. A successful probe closes the breaker and a failed probe
+// opens it for another cooldown, unless the origin has been down for longer
+// than the deadline. Only the outcome of the probe itself decides this, the
+// failures of requests that were already in flight are ignored.
+type circuitBreaker struct {
+	origin    string
+	threshold int
+	cooldown  time.Duration
+	deadline  time.Duration
+	failures  int
+	openUntil time.Time
+	downSince time.Time
+
+	// probe is the URL of the probe request in flight, if any.
+	probe string
+}
+
+// allow returns true if a request for the URL can be made to the origin. Once
+// the cooldown has passed the first request allowed is the probe.
+func (b *circuitBreaker) allow(now time.Time, pageURL string) bool {
+	if b.openUntil.IsZero() {
+		return true
+	}
+
+	if b.probe != "" || now.Before(b.openUntil) {
+		return false
+	}
+
+	b.probe = pageURL
+	return true
+}
+
+// release gives up the probe when the request for the URL was not made, so
+// that another request can probe the origin. True is returned if the URL was
+// the probe.
+func (b *circuitBreaker) release(pageURL string) bool {
+	if pageURL != b.probe {
+		return false
+	}
+
+	b.probe = ""
+	return true
+}
+
+// isOpen returns true while the breaker pauses the crawl of the origin.
+func (b *circuitBreaker) isOpen() bool {
+	return !b.openUntil.IsZero()
+}
+
+// record updates the breaker with the outcome of the request for the URL. An
+// error is returned when the probe fails past the deadline.
+func (b *circuitBreaker) record(
+	now time.Time,
+	pageURL string,
+	failed bool,
+) (breakerEvent, error) {
+	if !failed {
+		wasOpen := !b.openUntil.IsZero()
+		b.failures = 0
+		b.openUntil = time.Time{}
+		b.downSince = time.Time{}
+		b.probe = ""
+		if wasOpen {
+			return breakerClosed, nil
+		}
+		return breakerUnchanged, nil
+	}
+
+	b.failures++
+
+	if b.openUntil.IsZero() {
+		if b.failures < b.threshold {
+			return breakerUnchanged, nil
+		}
+		b.downSince = now
+		b.openUntil = now.Add(b.cooldown)
+		return breakerOpened, nil
+	}
+
+	// Failures of requests other than the probe were made before the breaker
+	// opened or before the probe was sent, and don't extend the cooldown.
+	if pageURL != b.probe {
+		return breakerUnchanged, nil
+	}
+
+	b.probe = ""
+	if now.Sub(b.downSince) >= b.deadline {
+		return breakerUnchanged, &OriginDownError{
+			Origin:    b.origin,
+			DownSince: b.downSince,
+			Deadline:  b.deadline,
+		}
+	}
+
+	b.openUntil = now.Add(b.cooldown)
+	return breakerOpened, nil
+}
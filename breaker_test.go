@@ -0,0 +1,319 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCircuitBreakerRecovers(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+	breaker := &circuitBreaker{
+		origin:    "http://example.com",
+		threshold: 3,
+		cooldown:  10 * time.Second,
+		deadline:  time.Minute,
+	}
+
+	steps := []struct {
+		at       int
+		url      string
+		failed   bool
+		expected breakerEvent
+	}{
+		{0, "/a", true, breakerUnchanged},
+		{0, "/b", true, breakerUnchanged},
+		{1, "/c", true, breakerOpened},
+		// A request made before the breaker opened
+		{2, "/d", true, breakerUnchanged},
+	}
+	for i, step := range steps {
+		event, err := breaker.record(at(step.at), step.url, step.failed)
+		if err != nil || event != step.expected {
+			t.Fatalf("step %d: expected %d but got %d (%v)", i, step.expected, event, err)
+		}
+	}
+
+	if breaker.allow(at(5), "/e") {
+		t.Errorf("expected requests to be refused during the cooldown")
+	}
+
+	if !breaker.allow(at(11), "/e") {
+		t.Errorf("expected a probe after the cooldown")
+	}
+
+	if breaker.allow(at(11), "/f") {
+		t.Errorf("expected a single probe at a time")
+	}
+
+	// A request made before the probe failing is not the probe result
+	if event, _ := breaker.record(at(12), "/d", true); event != breakerUnchanged {
+		t.Errorf("expected a request other than the probe to be ignored")
+	}
+
+	if breaker.allow(at(12), "/f") {
+		t.Errorf("expected the probe to still be in flight")
+	}
+
+	if event, _ := breaker.record(at(12), "/e", true); event != breakerOpened {
+		t.Errorf("expected a failed probe to open the breaker again")
+	}
+
+	if breaker.allow(at(21), "/f") {
+		t.Errorf("expected a failed probe to restart the cooldown")
+	}
+
+	if !breaker.allow(at(22), "/f") {
+		t.Errorf("expected a probe after the second cooldown")
+	}
+
+	if event, _ := breaker.record(at(22), "/f", false); event != breakerClosed {
+		t.Errorf("expected a successful probe to close the breaker")
+	}
+
+	if !breaker.allow(at(22), "/g") || !breaker.allow(at(22), "/h") {
+		t.Errorf("expected requests to be allowed once the breaker closed")
+	}
+}
+
+func TestCircuitBreakerDeadline(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := &circuitBreaker{
+		origin:    "http://example.com",
+		threshold: 1,
+		cooldown:  10 * time.Second,
+		deadline:  15 * time.Second,
+	}
+
+	breaker.record(start, "/a", true)
+
+	breaker.allow(start.Add(10*time.Second), "/b")
+	if _, err := breaker.record(start.Add(10*time.Second), "/b", true); err != nil {
+		t.Fatalf("expected no error before the deadline: %q", err)
+	}
+
+	breaker.allow(start.Add(20*time.Second), "/c")
+	_, err := breaker.record(start.Add(20*time.Second), "/c", true)
+
+	expectedErr := "crawl aborted: http://example.com has been failing " +
+		"since 2020-01-01T00:00:00Z, longer than 15s"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q but got %v", expectedErr, err)
+	}
+}
+
+func TestCrawlPausesFailingOrigin(t *testing.T) {
+	var lock sync.Mutex
+	failures := 0
+	var failedAt []time.Time
+
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			if r.URL.Path == "/" {
+				for i := 0; i < 10; i++ {
+					fmt.Fprintf(w, `<a href="/page-%d">page</a>`, i)
+				}
+				return
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if failures < 5 {
+				failures++
+				failedAt = append(failedAt, time.Now())
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		},
+	))
+	defer testServer.Close()
+
+	cooldown := 50 * time.Millisecond
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetMaxConcurrency(1),
+		SetBreakerThreshold(3),
+		SetBreakerCooldown(cooldown),
+		SetBreakerDeadline(time.Second),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	var failed, ok int
+	for _, page := range sitemap.Pages() {
+		switch page.StatusCode {
+		case http.StatusServiceUnavailable:
+			failed++
+		case http.StatusOK:
+			ok++
+		}
+	}
+
+	// The root is one of the ok pages
+	if failed != 5 || ok != 6 {
+		t.Errorf("expected 5 failed and 6 ok pages but got %d and %d", failed, ok)
+	}
+
+	// The fourth and fifth failures are probes made after a cooldown
+	for i := 3; i < len(failedAt); i++ {
+		if gap := failedAt[i].Sub(failedAt[i-1]); gap < cooldown {
+			t.Errorf("expected probe %d to wait for the cooldown but waited %s", i-2, gap)
+		}
+	}
+}
+
+func TestCrawlAbortsWhenOriginStaysDown(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			if r.URL.Path == "/" {
+				for i := 0; i < 10; i++ {
+					fmt.Fprintf(w, `<a href="/page-%d">page</a>`, i)
+				}
+				return
+			}
+
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	))
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetMaxConcurrency(1),
+		SetBreakerThreshold(2),
+		SetBreakerCooldown(10*time.Millisecond),
+		SetBreakerDeadline(30*time.Millisecond),
+		SetLogger(zap.NewNop()),
+	)
+
+	if _, ok := err.(*OriginDownError); !ok {
+		t.Fatalf("expected crawl to abort with OriginDownError but got %v", err)
+	}
+
+	if sitemap != nil {
+		t.Errorf("expected no site map for an aborted crawl")
+	}
+}
+
+func TestCrawlTimeoutWithOpenBreaker(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			if r.URL.Path == "/" {
+				for i := 0; i < 10; i++ {
+					fmt.Fprintf(w, `<a href="/page-%d">page</a>`, i)
+				}
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	))
+	defer testServer.Close()
+
+	type result struct {
+		sitemap *SiteMap
+		err     error
+	}
+	results := make(chan result)
+	go func() {
+		sitemap, err := CrawlDomain(
+			testServer.URL,
+			SetMaxConcurrency(1),
+			SetBreakerThreshold(1),
+			SetBreakerCooldown(100*time.Millisecond),
+			SetBreakerDeadline(time.Hour),
+			SetCrawlTimeout(50*time.Millisecond),
+			SetLogger(zap.NewNop()),
+		)
+		results <- result{sitemap, err}
+	}()
+
+	select {
+	case result := <-results:
+		if result.err != nil {
+			t.Fatalf("error crawling site: %q", result.err)
+		}
+		if !result.sitemap.Metadata().TimedOut {
+			t.Errorf("expected the crawl to time out")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected the crawl to end once it timed out")
+	}
+}
+
+func TestURLQueueReleasesSkippedProbe(t *testing.T) {
+	queue := newURLQueue(10, 10)
+	queue.newBreaker = func(origin string) *circuitBreaker {
+		return &circuitBreaker{
+			origin:    origin,
+			threshold: 1,
+			cooldown:  time.Millisecond,
+			deadline:  time.Hour,
+		}
+	}
+
+	for _, link := range []string{
+		"http://a.com/1",
+		"http://a.com/2",
+		"http://a.com/3",
+	} {
+		queue.push(mustParseURL(t, link))
+	}
+
+	failed, _ := queue.pop()
+	queue.report(failed, true)
+	queue.done(failed)
+	time.Sleep(5 * time.Millisecond)
+
+	// The probe is skipped without reporting its outcome
+	probe, _ := queue.pop()
+	queue.done(probe)
+
+	popped := make(chan *url.URL)
+	go func() {
+		pageURL, _ := queue.pop()
+		popped <- pageURL
+	}()
+
+	select {
+	case pageURL := <-popped:
+		if pageURL.String() != "http://a.com/3" {
+			t.Errorf("expected the next url to be the new probe but got %s", pageURL)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the skipped probe to be released")
+	}
+}
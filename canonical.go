@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// Reasons for a canonical URL to be reported by ValidateCanonicals.
+const (
+	CanonicalOffDomain  = "canonical off domain"
+	CanonicalNotCrawled = "canonical not crawled"
+	CanonicalNotOK      = "canonical not 200"
+)
+
+// CanonicalIssue is a page whose canonical URL can't be used in a sitemap.
+// Status is the status of the canonical URL, if it was crawled.
+type CanonicalIssue struct {
+	URL       string `json:"url"`
+	Canonical string `json:"canonical"`
+	Status    int    `json:"status,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// CanonicalReport lists the pages with problematic canonical URLs.
+type CanonicalReport struct {
+	Issues []CanonicalIssue `json:"issues"`
+}
+
+// isCanonicalPage returns true if the page does not declare a canonical URL
+// other than its own.
+func isCanonicalPage(page *Page) bool {
+	return page.Canonical == "" || page.Canonical == page.URL
+}
+
+// ValidateCanonicals reports the pages whose canonical URL points outside of
+// the domain, as decided by the domain validator, or to a URL that did not
+// respond with 200 OK. Canonical URLs in the domain are crawled even when no
+// page links to them, so they are only reported as not crawled when the crawl
+// stopped before reaching them.
+func (s *SiteMap) ValidateCanonicals() *CanonicalReport {
+	pages := s.Pages()
+
+	byURL := make(map[string]*Page, len(pages))
+	for i := range pages {
+		byURL[pages[i].URL] = &pages[i]
+	}
+
+	report := &CanonicalReport{Issues: []CanonicalIssue{}}
+
+	for _, page := range pages {
+		if page.Canonical == "" {
+			continue
+		}
+
+		issue := CanonicalIssue{URL: page.URL, Canonical: page.Canonical}
+
+		canonicalURL, err := url.Parse(page.Canonical)
+		if err != nil || !s.inScope(canonicalURL) {
+			issue.Reason = CanonicalOffDomain
+			report.Issues = append(report.Issues, issue)
+			continue
+		}
+
+		canonicalPage := byURL[page.Canonical]
+		if page.Canonical == page.URL {
+			canonicalPage = &page
+		}
+
+		switch {
+		case canonicalPage == nil || canonicalPage.StatusCode == 0:
+			issue.Reason = CanonicalNotCrawled
+		case canonicalPage.StatusCode != 200:
+			issue.Status = canonicalPage.StatusCode
+			issue.Reason = CanonicalNotOK
+		default:
+			continue
+		}
+
+		report.Issues = append(report.Issues, issue)
+	}
+
+	return report
+}
+
+// WriteText writes a human readable version of the report to the writer.
+func (r *CanonicalReport) WriteText(out io.Writer) error {
+	_, err := fmt.Fprintf(out, "Canonical issues: %d\n", len(r.Issues))
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range r.Issues {
+		reason := issue.Reason
+		if issue.Status != 0 {
+			reason = fmt.Sprintf("%s (%d)", reason, issue.Status)
+		}
+
+		_, err := fmt.Fprintf(out, "  %s -> %s: %s\n",
+			issue.URL,
+			issue.Canonical,
+			reason,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as a JSON object to the writer.
+func (r *CanonicalReport) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
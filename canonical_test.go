@@ -0,0 +1,204 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCrawlCanonicals(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error reading example site map: %q", err)
+	}
+
+	// The query variant of the hidden page is canonicalized to the hidden
+	// page, which responds with 200 OK.
+	if issues := sitemap.ValidateCanonicals().Issues; len(issues) != 0 {
+		t.Errorf("expected no canonical issues but got %v", issues)
+	}
+
+	var out bytes.Buffer
+	if err := sitemap.WriteXML(&out, CanonicalOnly()); err != nil {
+		t.Fatalf("error writing xml: %q", err)
+	}
+
+	xml := out.String()
+
+	if strings.Contains(xml, "/hidden?t=0") {
+		t.Errorf("expected non-canonical page to be left out\n\n%s", xml)
+	}
+
+	if !strings.Contains(xml, "<loc>"+testServer.URL+"/hidden</loc>") {
+		t.Errorf("expected canonical page to be included\n\n%s", xml)
+	}
+}
+
+func TestCrawlUnlinkedCanonicals(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			switch {
+			case r.URL.RawQuery != "":
+				fmt.Fprintf(w, `<link rel="canonical" href="%s">`, r.URL.Path)
+			case r.URL.Path == "/":
+				io.WriteString(w, `<a href="/a?x=1">a</a><a href="/b?x=1">b</a>`)
+			case r.URL.Path == "/b":
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+	))
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL+"/",
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	// Neither canonical is linked to, so both are crawled from the canonical
+	// link tags of the variants
+	expected := []CanonicalIssue{{
+		URL:       testServer.URL + "/b?x=1",
+		Canonical: testServer.URL + "/b",
+		Status:    http.StatusNotFound,
+		Reason:    CanonicalNotOK,
+	}}
+
+	issues := sitemap.ValidateCanonicals().Issues
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("expected canonical issues %v but got %v", expected, issues)
+	}
+
+	for _, page := range sitemap.Pages() {
+		if page.URL == testServer.URL+"/a" && len(page.Inlinks) != 0 {
+			t.Errorf("expected no inlinks for a canonical but got %v", page.Inlinks)
+		}
+	}
+}
+
+func TestCanonicalOnlyKeepsBrokenCanonicals(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/a?x=1", "status": 200,
+				"canonical": "http://example.com/a"},
+			{"url": "http://example.com/a", "status": 200},
+			{"url": "http://example.com/b?x=1", "status": 200,
+				"canonical": "http://example.com/b"},
+			{"url": "http://example.com/c?x=1", "status": 200,
+				"canonical": "http://other.com/c"},
+			{"url": "http://example.com/d?x=1", "status": 200,
+				"canonical": "http://example.com/d"},
+			{"url": "http://example.com/d", "status": 404}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading site map: %q", err)
+	}
+
+	var out bytes.Buffer
+	if err := sitemap.WriteXML(&out, CanonicalOnly()); err != nil {
+		t.Fatalf("error writing xml: %q", err)
+	}
+
+	xml := out.String()
+
+	if strings.Contains(xml, "<loc>http://example.com/a?x=1</loc>") {
+		t.Errorf("expected variant of a crawled canonical to be left out\n\n%s", xml)
+	}
+
+	// The canonical of /b was never crawled, the canonical of /c is in
+	// another domain and the canonical of /d is not found
+	for _, variant := range []string{"/b?x=1", "/c?x=1", "/d?x=1"} {
+		if !strings.Contains(xml, "<loc>http://example.com"+variant+"</loc>") {
+			t.Errorf(
+				"expected %s with a broken canonical to be kept\n\n%s",
+				variant,
+				xml,
+			)
+		}
+	}
+}
+
+func TestValidateCanonicals(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/a", "status": 200,
+				"canonical": "http://other.com/a"},
+			{"url": "http://example.com/b", "status": 200,
+				"canonical": "http://example.com/c"},
+			{"url": "http://example.com/c", "status": 301},
+			{"url": "http://example.com/d", "status": 200,
+				"canonical": "http://example.com/e"},
+			{"url": "http://example.com/f?x=1", "status": 200,
+				"canonical": "http://example.com/f"},
+			{"url": "http://example.com/f", "status": 200,
+				"canonical": "http://example.com/f"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	var out bytes.Buffer
+	if err := sitemap.ValidateCanonicals().WriteText(&out); err != nil {
+		t.Fatalf("error writing report: %q", err)
+	}
+
+	expected := "Canonical issues: 3\n" +
+		"  http://example.com/a -> http://other.com/a: " +
+		"canonical off domain\n" +
+		"  http://example.com/b -> http://example.com/c: " +
+		"canonical not 200 (301)\n" +
+		"  http://example.com/d -> http://example.com/e: " +
+		"canonical not crawled\n"
+
+	if out.String() != expected {
+		t.Errorf(
+			"unexpected report.\n\nGot:\n\n%s\n\nExpected:\n\n%s",
+			out.String(),
+			expected,
+		)
+	}
+}
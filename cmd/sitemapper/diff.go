@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Matt-Esch/sitemapper"
+)
+
+// runDiff compares two crawl snapshots written with -snapshot and reports the
+// added, removed and changed URLs.
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: sitemapper diff [options] <old> <new>\n")
+		flags.PrintDefaults()
+	}
+	formatPtr := flags.String("format", "text", "output format (text, json)")
+
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if *formatPtr != "text" && *formatPtr != "json" {
+		log.Fatalf("error: unknown format %q", *formatPtr)
+	}
+
+	previous, previousErr := readSnapshot(flags.Arg(0))
+	if previousErr != nil {
+		log.Fatalf("error: %s", previousErr)
+	}
+
+	current, currentErr := readSnapshot(flags.Arg(1))
+	if currentErr != nil {
+		log.Fatalf("error: %s", currentErr)
+	}
+
+	diff := sitemapper.DiffSiteMaps(previous, current)
+
+	var writeErr error
+	if *formatPtr == "json" {
+		writeErr = diff.WriteJSON(os.Stdout)
+	} else {
+		writeErr = diff.WriteText(os.Stdout)
+	}
+
+	if writeErr != nil {
+		log.Fatalf("error: %s", writeErr)
+	}
+}
+
+// readSnapshot loads a site map snapshot from the named file.
+func readSnapshot(fileName string) (*sitemapper.SiteMap, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return sitemapper.ReadSiteMap(file)
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+// runHreflang crawls a site and reports the hreflang alternates which are not
+// reciprocal.
+func runHreflang(args []string) {
+	flags := flag.NewFlagSet("hreflang", flag.ExitOnError)
+	crawlFlags := newCrawlFlags(flags)
+	formatPtr := flags.String("format", "text", "output format (text, json)")
+
+	flags.Parse(args)
+
+	if *formatPtr != "text" && *formatPtr != "json" {
+		log.Fatalf("error: unknown format %q", *formatPtr)
+	}
+
+	siteMap, siteMapErr := crawlFlags.crawl()
+	if siteMapErr != nil {
+		log.Fatalf("error: %s", siteMapErr)
+	}
+
+	report := siteMap.ValidateAlternates()
+
+	var writeErr error
+	if *formatPtr == "json" {
+		writeErr = report.WriteJSON(os.Stdout)
+	} else {
+		writeErr = report.WriteText(os.Stdout)
+	}
+
+	if writeErr != nil {
+		log.Fatalf("error: %s", writeErr)
+	}
+}
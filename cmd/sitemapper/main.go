@@ -21,10 +21,19 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Matt-Esch/sitemapper"
@@ -32,56 +41,749 @@ import (
 )
 
 const concurrency int = 8
+const hostConcurrency int = sitemapper.DefaultMaxHostConcurrency
 const crawlTimeout time.Duration = 0
 const timeout time.Duration = 30 * time.Second
 const keepAlive time.Duration = sitemapper.DefaultKeepAlive
 
 func main() {
-	urlPtr := flag.String("u", "", "url to crawl (required)")
-	concPtr := flag.Int("c", concurrency, "maximum concurrency")
-	crawlTimeoutPtr := flag.Duration("w", crawlTimeout, "maximum crawl time")
-	timeoutPtr := flag.Duration("t", timeout, "http request timeout")
-	keepAlivePtr := flag.Duration("k", keepAlive, "http keep alive timeout")
-	verbosePtr := flag.Bool("v", false, "enable verbose logging")
-	debugPtr := flag.Bool("d", false, "enable debug logs")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "orphans":
+			runOrphans(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "hreflang":
+			runHreflang(os.Args[2:])
+			return
+		case "canonical":
+			runCanonical(os.Args[2:])
+			return
+		}
+	}
 
+	crawlFlags := newCrawlFlags(flag.CommandLine)
+	snapshotPtr := flag.String(
+		"snapshot",
+		"",
+		"write a json snapshot of the crawl to a file",
+	)
+	formatPtr := flag.String(
+		"format",
+		"text",
+		"output format (text, ndjson, csv, html, junit, tree, xml)",
+	)
+	columnsPtr := flag.String(
+		"columns",
+		strings.Join(sitemapper.DefaultCSVColumns, ","),
+		"comma separated columns for csv output",
+	)
+	treeCountsPtr := flag.Bool(
+		"tree-counts",
+		false,
+		"show page counts per subtree in tree output",
+	)
+	treeStatusPtr := flag.Bool(
+		"tree-status",
+		false,
+		"show status codes in tree output",
+	)
+	canonicalOnlyPtr := flag.Bool(
+		"canonical-only",
+		false,
+		"leave non-canonical urls out of xml output",
+	)
+	priorityPtr := flag.String(
+		"priority",
+		"",
+		"compute xml priority from page depth or inlinks (depth, inlinks)",
+	)
+	sitemapRulesPtr := flag.String(
+		"sitemap-rules",
+		"",
+		"file of url patterns setting xml changefreq and priority",
+	)
+	outputPtr := flag.String(
+		"o",
+		"",
+		"write xml sitemaps to a file, gzip compressed if it ends in .gz",
+	)
+	previousSnapshotPtr := flag.String(
+		"previous-snapshot",
+		"",
+		"json snapshot of a previous crawl used to infer lastmod",
+	)
 	flag.Parse()
 
-	if *urlPtr == "" {
-		flag.Usage()
-		os.Exit(1)
+	var opts []sitemapper.Option
+	var csvColumns []string
+	switch *formatPtr {
+	case "text", "html", "junit", "tree", "xml":
+	case "csv":
+		var columnsErr error
+		csvColumns, columnsErr = sitemapper.ParseCSVColumns(*columnsPtr)
+		if columnsErr != nil {
+			log.Fatalf("error: %s", columnsErr)
+		}
+	case "ndjson":
+		handler := newNDJSONHandler(os.Stdout)
+		opts = append(opts, sitemapper.SetPageHandler(handler))
+	default:
+		log.Fatalf("error: unknown format %q", *formatPtr)
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			MaxIdleConns:        *concPtr,
-			MaxIdleConnsPerHost: *concPtr,
-			MaxConnsPerHost:     *concPtr,
-			IdleConnTimeout:     *keepAlivePtr,
-		},
-		Timeout: *timeoutPtr,
+	if *outputPtr != "" && *formatPtr != "xml" {
+		log.Fatalf("error: -o is only supported with -format xml")
+	}
+
+	var xmlOpts []sitemapper.XMLOption
+	if *canonicalOnlyPtr {
+		xmlOpts = append(xmlOpts, sitemapper.CanonicalOnly())
+	}
+	switch *priorityPtr {
+	case "":
+	case "depth":
+		xmlOpts = append(xmlOpts, sitemapper.PriorityByDepth())
+	case "inlinks":
+		xmlOpts = append(xmlOpts, sitemapper.PriorityByInlinks())
+	default:
+		log.Fatalf("error: unknown priority %q", *priorityPtr)
+	}
+	if *sitemapRulesPtr != "" {
+		rules, rulesErr := readSitemapRules(*sitemapRulesPtr)
+		if rulesErr != nil {
+			log.Fatalf("error: %s", rulesErr)
+		}
+		xmlOpts = append(xmlOpts, sitemapper.ApplySitemapRules(rules))
+	}
+
+	var previous *sitemapper.SiteMap
+	if *previousSnapshotPtr != "" {
+		var previousErr error
+		previous, previousErr = readSnapshot(*previousSnapshotPtr)
+		if previousErr != nil {
+			log.Fatalf("error: %s", previousErr)
+		}
+	}
+
+	siteMap, siteMapErr := crawlFlags.crawl(opts...)
+	if siteMapErr != nil {
+		log.Fatalf("error: %s", siteMapErr)
+	}
+
+	if previous != nil {
+		siteMap.InferLastModified(previous)
+	}
+
+	if *snapshotPtr != "" {
+		if err := writeSnapshot(*snapshotPtr, siteMap); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	}
+
+	// Streaming formats have written each page during the crawl
+	switch *formatPtr {
+	case "text":
+		siteMap.WriteMap(os.Stdout)
+	case "csv":
+		if err := siteMap.WriteCSV(os.Stdout, csvColumns...); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	case "html":
+		if err := siteMap.WriteHTMLReport(os.Stdout); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	case "junit":
+		if err := siteMap.WriteJUnit(os.Stdout); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	case "tree":
+		options := sitemapper.TreeOptions{
+			Counts: *treeCountsPtr,
+			Status: *treeStatusPtr,
+		}
+		if err := siteMap.WriteTree(os.Stdout, options); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	case "xml":
+		if *outputPtr != "" {
+			_, err := siteMap.WriteXMLFiles(*outputPtr, xmlOpts...)
+			if err != nil {
+				log.Fatalf("error: %s", err)
+			}
+		} else if err := siteMap.WriteXML(os.Stdout, xmlOpts...); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	}
+}
+
+// newNDJSONHandler returns a page handler that writes each page to the writer
+// as a single line of JSON as soon as it has been fetched.
+func newNDJSONHandler(out io.Writer) sitemapper.PageHandler {
+	var lock sync.Mutex
+	encoder := json.NewEncoder(out)
+
+	return sitemapper.PageHandlerFunc(func(page sitemapper.Page) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err := encoder.Encode(page); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	})
+}
+
+// writeSnapshot saves the site map to the named file.
+func writeSnapshot(fileName string, siteMap *sitemapper.SiteMap) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	if err := siteMap.WriteJSON(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// readSitemapRules reads the sitemap rules from the named file.
+func readSitemapRules(fileName string) ([]sitemapper.SitemapRule, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return sitemapper.ReadSitemapRules(file)
+}
+
+// crawlFlags are the command line options shared by all commands that crawl
+// a site.
+type crawlFlags struct {
+	flags            *flag.FlagSet
+	urls             stringsFlag
+	seeds            *string
+	concurrency      *int
+	adaptive         *bool
+	stats            *bool
+	breaker          *int
+	breakerCooldown  *time.Duration
+	breakerDeadline  *time.Duration
+	hostConcurrency  *int
+	crawlTimeout     *time.Duration
+	timeout          *time.Duration
+	keepAlive        *time.Duration
+	verbose          *bool
+	debug            *bool
+	sameDomainImages *bool
+	imageHosts       stringsFlag
+	ignoreNoFollow   *bool
+	ignoreNoIndex    *bool
+	userAgent        *string
+	headers          stringsFlag
+	basicAuth        *string
+	bearerToken      *string
+	cookies          *string
+	loginURL         *string
+	loginFields      stringsFlag
+	loginExpired     *string
+	resolve          stringsFlag
+	blockPrivate     *bool
+	denyCIDRs        stringsFlag
+	allowCIDRs       stringsFlag
+	domain           *string
+	allowHosts       stringsFlag
+	excludeHosts     stringsFlag
+}
+
+func newCrawlFlags(flags *flag.FlagSet) *crawlFlags {
+	f := &crawlFlags{
+		flags:       flags,
+		seeds:       flags.String("seeds", "", "file of urls to crawl, one per line"),
+		concurrency: flags.Int("c", concurrency, "maximum concurrency"),
+		adaptive: flags.Bool(
+			"adaptive",
+			false,
+			"adapt concurrency up to -c to server latency and errors",
+		),
+		stats: flags.Bool(
+			"stats",
+			false,
+			"print crawl statistics to stderr",
+		),
+		breaker: flags.Int(
+			"breaker",
+			0,
+			"pause an origin after this many consecutive failures (0 disables)",
+		),
+		breakerCooldown: flags.Duration(
+			"breaker-cooldown",
+			sitemapper.DefaultBreakerCooldown,
+			"pause before probing an origin with an open -breaker",
+		),
+		breakerDeadline: flags.Duration(
+			"breaker-deadline",
+			sitemapper.DefaultBreakerDeadline,
+			"abort the crawl when an origin is down for longer",
+		),
+		hostConcurrency: flags.Int(
+			"host-concurrency",
+			hostConcurrency,
+			"maximum concurrency per host",
+		),
+		crawlTimeout: flags.Duration("w", crawlTimeout, "maximum crawl time"),
+		timeout:      flags.Duration("t", timeout, "http request timeout"),
+		keepAlive:    flags.Duration("k", keepAlive, "http keep alive timeout"),
+		verbose:      flags.Bool("v", false, "enable verbose logging"),
+		debug:        flags.Bool("d", false, "enable debug logs"),
+		sameDomainImages: flags.Bool(
+			"same-domain-images",
+			false,
+			"only collect images in the crawled domain or an -image-host",
+		),
+		ignoreNoFollow: flags.Bool(
+			"ignore-nofollow",
+			false,
+			"follow links marked nofollow by rel or robots directives",
+		),
+		ignoreNoIndex: flags.Bool(
+			"ignore-noindex",
+			false,
+			"keep pages marked noindex by robots directives in the output",
+		),
+		userAgent: flags.String("A", "", "user agent sent with every request"),
+		basicAuth: flags.String(
+			"basic-auth",
+			"",
+			"\"user:password\" for http basic authentication",
+		),
+		bearerToken: flags.String(
+			"bearer-token",
+			"",
+			"bearer token sent in the authorization header",
+		),
+		cookies: flags.String(
+			"cookies",
+			"",
+			"netscape cookies.txt file of cookies sent with requests",
+		),
+		loginURL: flags.String(
+			"login-url",
+			"",
+			"url of a login form submitted before crawling",
+		),
+		loginExpired: flags.String(
+			"login-expired",
+			"",
+			"pattern of redirect locations that mean the login has expired",
+		),
+		domain: flags.String(
+			"domain",
+			"host",
+			"links to crawl (host, origin, www, subdomains)",
+		),
+		blockPrivate: flags.Bool(
+			"block-private",
+			false,
+			"refuse to connect to loopback, link-local and private addresses",
+		),
+	}
+
+	flags.Var(&f.urls, "u", "url to crawl (required, repeatable)")
+	flags.Var(
+		&f.imageHosts,
+		"image-host",
+		"host allowed for images with -same-domain-images (repeatable)",
+	)
+	flags.Var(
+		&f.loginFields,
+		"login-field",
+		"form field \"name=value\" submitted to -login-url (repeatable)",
+	)
+	flags.Var(
+		&f.resolve,
+		"resolve",
+		"connect to \"host:port:address\" instead of resolving the host (repeatable)",
+	)
+	flags.Var(
+		&f.denyCIDRs,
+		"deny-cidr",
+		"refuse to connect to addresses in the network (repeatable)",
+	)
+	flags.Var(
+		&f.allowCIDRs,
+		"allow-cidr",
+		"allow connections to the network despite -block-private or -deny-cidr (repeatable)",
+	)
+	flags.Var(
+		&f.allowHosts,
+		"allow-host",
+		"also crawl links to the host (repeatable)",
+	)
+	flags.Var(
+		&f.excludeHosts,
+		"exclude-host",
+		"never crawl links to the host (repeatable)",
+	)
+	flags.Var(
+		&f.headers,
+		"H",
+		"header \"Name: value\" sent with every request (repeatable)",
+	)
+
+	return f
+}
+
+// stringsFlag is a flag that can be repeated to build a list of values.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// client returns an http client for requests made outside of the crawl. It
+// is configured like the client of the crawler, sends the same headers and
+// credentials, but follows redirects.
+func (f *crawlFlags) client() (*http.Client, error) {
+	opts, err := f.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	headerOpts, err := f.headerOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := f.validator()
+	if err != nil {
+		return nil, err
+	}
+
+	roots, err := f.rootURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	config := sitemapper.NewConfig(append(opts, headerOpts...)...)
+	inScope := func(link *url.URL) bool {
+		for _, root := range roots {
+			if validator.Validate(root, link) {
+				return true
+			}
+		}
+		return false
+	}
+
+	client := *config.Client
+	client.CheckRedirect = nil
+	client.Transport = config.Transport(client.Transport, inScope)
+	return &client, nil
+}
+
+// headerOptions returns the options that set the headers, credentials and
+// cookies sent with requests.
+func (f *crawlFlags) headerOptions() ([]sitemapper.Option, error) {
+	opts := []sitemapper.Option{
+		sitemapper.SetUserAgent(*f.userAgent),
+		sitemapper.SetBearerToken(*f.bearerToken),
 	}
 
-	logger, loggerErr := newLogger(*verbosePtr, *debugPtr)
+	if *f.basicAuth != "" {
+		parts := strings.SplitN(*f.basicAuth, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid basic auth, expected \"user:password\"")
+		}
+		opts = append(opts, sitemapper.SetBasicAuth(parts[0], parts[1]))
+	}
+
+	if *f.cookies != "" {
+		cookies, err := readCookies(*f.cookies)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sitemapper.SetCookies(cookies...))
+	}
+
+	for _, header := range f.headers {
+		parts := strings.SplitN(header, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf(
+				"invalid header %q, expected \"Name: value\"",
+				header,
+			)
+		}
+
+		value := strings.TrimSpace(parts[1])
+		opts = append(opts, sitemapper.SetHeader(name, value))
+	}
+
+	return opts, nil
+}
+
+// clientOptions returns the options that configure the default http client
+// of the crawler.
+func (f *crawlFlags) clientOptions() ([]sitemapper.Option, error) {
+	opts := []sitemapper.Option{
+		sitemapper.SetMaxConcurrency(*f.concurrency),
+		sitemapper.SetMaxHostConcurrency(*f.hostConcurrency),
+		sitemapper.SetKeepAlive(*f.keepAlive),
+		sitemapper.SetTimeout(*f.timeout),
+	}
+
+	for _, resolve := range f.resolve {
+		// The address may be an IPv6 address in brackets, as with curl
+		parts := strings.SplitN(resolve, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf(
+				"invalid resolve %q, expected \"host:port:address\"",
+				resolve,
+			)
+		}
+
+		hostPort := net.JoinHostPort(parts[0], parts[1])
+		address := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+		opts = append(opts, sitemapper.SetResolve(hostPort, address))
+	}
+
+	if *f.blockPrivate {
+		opts = append(opts, sitemapper.SetDeniedNetworks(
+			sitemapper.PrivateNetworks()...,
+		))
+	}
+
+	denied, err := sitemapper.ParseNetworks(f.denyCIDRs...)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := sitemapper.ParseNetworks(f.allowCIDRs...)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts,
+		sitemapper.SetDeniedNetworks(denied...),
+		sitemapper.SetAllowedNetworks(allowed...),
+	)
+
+	return opts, nil
+}
+
+// crawl crawls the urls given on the command line into one site map with any
+// additional options. The usage is printed and the process exits if no url was
+// given.
+func (f *crawlFlags) crawl(opts ...sitemapper.Option) (*sitemapper.SiteMap, error) {
+	roots, err := f.rootURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roots) == 0 {
+		f.flags.Usage()
+		os.Exit(1)
+	}
+
+	logger, loggerErr := newLogger(*f.verbose, *f.debug)
 	if loggerErr != nil {
-		log.Fatalf("error: %s", loggerErr)
+		return nil, loggerErr
 	}
 
-	siteMap, siteMapErr := sitemapper.CrawlDomain(
-		*urlPtr,
-		sitemapper.SetMaxConcurrency(*concPtr),
-		sitemapper.SetCrawlTimeout(*crawlTimeoutPtr),
-		sitemapper.SetKeepAlive(*keepAlivePtr),
-		sitemapper.SetTimeout(*timeoutPtr),
-		sitemapper.SetClient(client),
+	crawlOpts, err := f.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := f.validator()
+	if err != nil {
+		return nil, err
+	}
+
+	crawlOpts = append(crawlOpts,
+		sitemapper.SetDomainValidator(validator),
+		sitemapper.SetAdaptiveConcurrency(*f.adaptive),
+		sitemapper.SetBreakerThreshold(*f.breaker),
+		sitemapper.SetBreakerCooldown(*f.breakerCooldown),
+		sitemapper.SetBreakerDeadline(*f.breakerDeadline),
+		sitemapper.SetCrawlTimeout(*f.crawlTimeout),
 		sitemapper.SetLogger(logger),
+		sitemapper.SetSameDomainImages(*f.sameDomainImages),
+		sitemapper.SetImageHosts(f.imageHosts...),
+		sitemapper.SetIgnoreNoFollow(*f.ignoreNoFollow),
+		sitemapper.SetIgnoreNoIndex(*f.ignoreNoIndex),
 	)
 
-	if siteMapErr != nil {
-		log.Fatalf("error: %s", siteMapErr)
+	headerOpts, err := f.headerOptions()
+	if err != nil {
+		return nil, err
+	}
+	crawlOpts = append(crawlOpts, headerOpts...)
+
+	if *f.loginURL != "" {
+		form, err := f.loginForm()
+		if err != nil {
+			return nil, err
+		}
+		crawlOpts = append(crawlOpts, sitemapper.SetLoginForm(form))
+	}
+
+	config := sitemapper.NewConfig(append(crawlOpts, opts...)...)
+	crawler, err := sitemapper.NewMultiDomainCrawler(roots, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stats are printed even when the crawl fails, as they help to explain
+	// why it failed.
+	siteMap, crawlErr := crawler.Crawl()
+	if *f.stats {
+		if err := crawler.Stats().WriteSummary(os.Stderr); err != nil {
+			return nil, err
+		}
+	}
+
+	return siteMap, crawlErr
+}
+
+// roots returns the urls given with -u followed by the urls in the -seeds
+// file.
+func (f *crawlFlags) roots() ([]string, error) {
+	roots := append([]string{}, f.urls...)
+	if *f.seeds == "" {
+		return roots, nil
+	}
+
+	seeds, err := readSeeds(*f.seeds)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(roots, seeds...), nil
+}
+
+// rootURLs returns the parsed urls given with -u and -seeds.
+func (f *crawlFlags) rootURLs() ([]*url.URL, error) {
+	roots, err := f.roots()
+	if err != nil {
+		return nil, err
+	}
+
+	rootURLs := make([]*url.URL, 0, len(roots))
+	for _, root := range roots {
+		rootURL, err := url.Parse(root)
+		if err != nil {
+			return nil, err
+		}
+		rootURLs = append(rootURLs, rootURL)
+	}
+
+	return rootURLs, nil
+}
+
+// validator returns the domain validator given on the command line.
+func (f *crawlFlags) validator() (sitemapper.DomainValidator, error) {
+	var validator sitemapper.DomainValidator
+	switch *f.domain {
+	case "host":
+		validator = sitemapper.DomainValidatorFunc(sitemapper.ValidateHosts)
+	case "origin":
+		validator = sitemapper.DomainValidatorFunc(sitemapper.ValidateOrigin)
+	case "www":
+		validator = sitemapper.DomainValidatorFunc(
+			sitemapper.ValidateHostsIgnoringWWW,
+		)
+	case "subdomains":
+		validator = sitemapper.DomainValidatorFunc(
+			sitemapper.ValidateSubdomains,
+		)
+	default:
+		return nil, fmt.Errorf("unknown domain %q", *f.domain)
+	}
+
+	if len(f.allowHosts) > 0 {
+		validator = sitemapper.Or(
+			validator,
+			sitemapper.AllowHosts(f.allowHosts...),
+		)
+	}
+
+	if len(f.excludeHosts) > 0 {
+		validator = sitemapper.And(
+			validator,
+			sitemapper.Not(sitemapper.AllowHosts(f.excludeHosts...)),
+		)
+	}
+
+	return validator, nil
+}
+
+// loginForm returns the login form given on the command line.
+func (f *crawlFlags) loginForm() (*sitemapper.LoginForm, error) {
+	form := &sitemapper.LoginForm{URL: *f.loginURL, Fields: url.Values{}}
+
+	for _, field := range f.loginFields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf(
+				"invalid login field %q, expected \"name=value\"",
+				field,
+			)
+		}
+		form.Fields.Add(parts[0], parts[1])
+	}
+
+	if *f.loginExpired != "" {
+		pattern, err := regexp.Compile(*f.loginExpired)
+		if err != nil {
+			return nil, err
+		}
+		form.ExpiredRedirect = pattern
+	}
+
+	return form, nil
+}
+
+// readSeeds reads the urls in the named file. Blank lines and lines starting
+// with # are ignored.
+func readSeeds(fileName string) ([]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var seeds []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+
+	return seeds, scanner.Err()
+}
+
+// readCookies reads the cookies from the named cookies.txt file.
+func readCookies(fileName string) ([]*http.Cookie, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	siteMap.WriteMap(os.Stdout)
+	return sitemapper.ReadNetscapeCookies(file)
 }
 
 func newLogger(verbose bool, debug bool) (*zap.Logger, error) {
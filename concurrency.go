@@ -0,0 +1,218 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// adaptiveMaxFailureRate is the share of failed requests in a window above
+// which adaptive concurrency is halved.
+const adaptiveMaxFailureRate = 0.1
+
+// adaptiveLatencyFactor is how many times slower than the baseline latency
+// the requests of a window can be before adaptive concurrency is halved.
+const adaptiveLatencyFactor = 2
+
+// ConcurrencySample records the number of pages crawled at once from a point
+// in time during the crawl.
+type ConcurrencySample struct {
+	Time        time.Time `json:"time"`
+	Concurrency int       `json:"concurrency"`
+}
+
+// concurrencyLimiter limits the number of pages crawled at once. In adaptive
+// mode the limit is adjusted with additive increase, multiplicative decrease
+// (AIMD) after each window of requests, where a window is as many requests as
+// the current limit. The limit starts at 1 and doubles after each healthy
+// window until the first decrease, then grows by 1. It is halved when too many
+// requests in the window failed with 429, 5xx or no response, or when their
+// average latency is well above the baseline, which is the lowest average
+// latency seen and slowly follows slower windows.
+type concurrencyLimiter struct {
+	lock      sync.Mutex
+	cond      *sync.Cond
+	adaptive  bool
+	limit     int
+	max       int
+	active    int
+	slowStart bool
+	requests  int
+	failures  int
+	latencies time.Duration
+	baseline  time.Duration
+	history   []ConcurrencySample
+	logger    *zap.Logger
+}
+
+func newConcurrencyLimiter(
+	max int,
+	adaptive bool,
+	logger *zap.Logger,
+) *concurrencyLimiter {
+	limit := max
+	if adaptive {
+		limit = 1
+	}
+
+	limiter := &concurrencyLimiter{
+		adaptive:  adaptive,
+		limit:     limit,
+		max:       max,
+		slowStart: true,
+		logger:    logger,
+	}
+	limiter.cond = sync.NewCond(&limiter.lock)
+
+	return limiter
+}
+
+// start records the initial limit at the start of the crawl.
+func (l *concurrencyLimiter) start(now time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.history = append(l.history, ConcurrencySample{
+		Time:        now,
+		Concurrency: l.limit,
+	})
+}
+
+// acquire blocks until fewer pages than the limit are being crawled.
+func (l *concurrencyLimiter) acquire() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+// release frees the slot taken by acquire.
+func (l *concurrencyLimiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.active--
+	l.cond.Signal()
+}
+
+// observe records the outcome of a request and adjusts the limit at the end
+// of each window in adaptive mode.
+func (l *concurrencyLimiter) observe(
+	latency time.Duration,
+	statusCode int,
+	err error,
+) {
+	if !l.adaptive {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.requests++
+	l.latencies += latency
+	if isOverloaded(statusCode, err) {
+		l.failures++
+	}
+
+	if l.requests < l.limit {
+		return
+	}
+
+	average := l.latencies / time.Duration(l.requests)
+	failureRate := float64(l.failures) / float64(l.requests)
+	l.requests, l.failures, l.latencies = 0, 0, 0
+
+	if l.baseline == 0 || average < l.baseline {
+		l.baseline = average
+	} else {
+		l.baseline += (average - l.baseline) / 8
+	}
+
+	previous := l.limit
+	switch {
+	case failureRate > adaptiveMaxFailureRate ||
+		average > l.baseline*adaptiveLatencyFactor:
+		l.slowStart = false
+		l.limit /= 2
+		if l.limit < 1 {
+			l.limit = 1
+		}
+	case l.slowStart:
+		l.limit *= 2
+	default:
+		l.limit++
+	}
+
+	if l.limit > l.max {
+		l.limit = l.max
+	}
+
+	if l.limit == previous {
+		return
+	}
+
+	l.history = append(l.history, ConcurrencySample{
+		Time:        time.Now().UTC(),
+		Concurrency: l.limit,
+	})
+	l.logger.Debug("concurrency changed",
+		zap.Int("concurrency", l.limit),
+		zap.Int("previous", previous),
+		zap.Duration("latency", average),
+		zap.Duration("baseline", l.baseline),
+		zap.Float64("failureRate", failureRate),
+	)
+	l.cond.Broadcast()
+}
+
+// samples returns the limits chosen during the crawl.
+func (l *concurrencyLimiter) samples() []ConcurrencySample {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return append([]ConcurrencySample(nil), l.history...)
+}
+
+// ConcurrencyHistory returns the concurrency chosen over time by a crawl with
+// adaptive concurrency, starting with the initial concurrency. It is empty
+// for other crawls.
+func (s *SiteMap) ConcurrencyHistory() []ConcurrencySample {
+	return append([]ConcurrencySample(nil), s.stats.Concurrency...)
+}
+
+// isOverloaded returns true if the response suggests the server is
+// struggling: the request got no response, was rate limited or failed with a
+// server error.
+func isOverloaded(statusCode int, err error) bool {
+	if statusCode == 0 {
+		return err != nil
+	}
+
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestConcurrencyLimiterAIMD(t *testing.T) {
+	limiter := newConcurrencyLimiter(8, true, zap.NewNop())
+
+	observeWindow := func(latency time.Duration, statusCode int) int {
+		for i, n := 0, limiter.limit; i < n; i++ {
+			limiter.observe(latency, statusCode, nil)
+		}
+		return limiter.limit
+	}
+
+	var limits []int
+	for i := 0; i < 4; i++ {
+		limits = append(limits, observeWindow(10*time.Millisecond, 200))
+	}
+	limits = append(limits, observeWindow(10*time.Millisecond, 503))
+	limits = append(limits, observeWindow(10*time.Millisecond, 200))
+	limits = append(limits, observeWindow(100*time.Millisecond, 200))
+	limits = append(limits, observeWindow(10*time.Millisecond, 429))
+
+	expected := []int{2, 4, 8, 8, 4, 5, 2, 1}
+	if !reflect.DeepEqual(limits, expected) {
+		t.Errorf("expected limits %v but got %v", expected, limits)
+	}
+
+	var history []int
+	for _, sample := range limiter.samples() {
+		history = append(history, sample.Concurrency)
+	}
+
+	expectedHistory := []int{2, 4, 8, 4, 5, 2, 1}
+	if !reflect.DeepEqual(history, expectedHistory) {
+		t.Errorf("expected history %v but got %v", expectedHistory, history)
+	}
+}
+
+func TestConcurrencyLimiterFixed(t *testing.T) {
+	limiter := newConcurrencyLimiter(4, false, zap.NewNop())
+
+	for i := 0; i < 10; i++ {
+		limiter.observe(time.Second, 503, nil)
+	}
+
+	if limiter.limit != 4 {
+		t.Errorf("expected fixed limit of 4 but got %d", limiter.limit)
+	}
+
+	if samples := limiter.samples(); len(samples) != 0 {
+		t.Errorf("expected no samples but got %v", samples)
+	}
+}
+
+func TestConcurrencyLimiterAcquire(t *testing.T) {
+	limiter := newConcurrencyLimiter(1, false, zap.NewNop())
+	limiter.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("expected acquire to block at the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.release()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("expected acquire to succeed after release")
+	}
+}
+
+func TestIsOverloaded(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		err        error
+		expected   bool
+	}{
+		{200, nil, false},
+		{404, nil, false},
+		{429, nil, true},
+		{500, nil, true},
+		{503, errors.New("read error"), true},
+		{0, errors.New("connection refused"), true},
+		{200, errors.New("read error"), false},
+	}
+
+	for _, test := range tests {
+		if actual := isOverloaded(test.statusCode, test.err); actual != test.expected {
+			t.Errorf(
+				"expected isOverloaded(%d, %v) to be %t",
+				test.statusCode,
+				test.err,
+				test.expected,
+			)
+		}
+	}
+}
+
+func TestCrawlAdaptiveConcurrency(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetMaxConcurrency(4),
+		SetAdaptiveConcurrency(true),
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	history := sitemap.ConcurrencyHistory()
+	if len(history) == 0 || history[0].Concurrency != 1 {
+		t.Fatalf("expected history to start with concurrency 1: %v", history)
+	}
+
+	for _, sample := range history {
+		if sample.Concurrency < 1 || sample.Concurrency > 4 {
+			t.Errorf("expected concurrency within 1 and 4: %v", history)
+		}
+	}
+
+	fixed, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	if history := fixed.ConcurrencyHistory(); len(history) != 0 {
+		t.Errorf("expected no history without adaptive concurrency: %v", history)
+	}
+}
@@ -21,11 +21,18 @@
 package sitemapper
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/net/publicsuffix"
 )
 
 // DefaultMaxConcurrency sets the number of goroutines to be used to crawl
@@ -34,6 +41,12 @@ import (
 // goroutines used.
 const DefaultMaxConcurrency = 8
 
+// DefaultMaxHostConcurrency limits the number of pages of a single origin that
+// are crawled at once, so that no site gets every goroutine. When several hosts
+// are crawled, URLs are scheduled round-robin across hosts so each host gets a
+// fair share of the goroutines.
+const DefaultMaxHostConcurrency = 4
+
 // DefaultMaxPendingURLS limits the size of the URLS list. This prevents us from
 // increasing the URLS list faster than we can drain it. This wouldn't normally
 // expect to happen, but there could be cases where URLs are poorly designed
@@ -53,28 +66,70 @@ const DefaultKeepAlive = time.Second * 30
 
 // Config is a stuct of crawler configuration options.
 type Config struct {
-	MaxConcurrency  int
-	MaxPendingURLS  int
-	CrawlTimeout    time.Duration
-	KeepAlive       time.Duration
-	Timeout         time.Duration
-	Client          *http.Client
-	Logger          *zap.Logger
-	DomainValidator DomainValidator
+	MaxConcurrency      int
+	AdaptiveConcurrency bool
+	MaxHostConcurrency  int
+	MaxPendingURLS      int
+	BreakerThreshold    int
+	BreakerCooldown     time.Duration
+	BreakerDeadline     time.Duration
+	CrawlTimeout        time.Duration
+	KeepAlive           time.Duration
+	Timeout             time.Duration
+	Client              *http.Client
+	Logger              *zap.Logger
+	DomainValidator     DomainValidator
+	PageHandler         PageHandler
+	SameDomainImages    bool
+	ImageHosts          []string
+	IgnoreNoFollow      bool
+	IgnoreNoIndex       bool
+	UserAgent           string
+	Headers             http.Header
+	BasicAuthUsername   string
+	BasicAuthPassword   string
+	BearerToken         string
+	CookieJar           http.CookieJar
+	Cookies             []*http.Cookie
+	LoginForm           *LoginForm
+	Resolve             map[string]string
+	DeniedNetworks      []*net.IPNet
+	AllowedNetworks     []*net.IPNet
 }
 
 // NewConfig creates a config from the specified options, and provides
 // defaults for options which are not specified
 func NewConfig(options ...Option) *Config {
 	config := &Config{
-		MaxConcurrency:  DefaultMaxConcurrency,
-		MaxPendingURLS:  DefaultMaxPendingURLS,
-		CrawlTimeout:    DefaultCrawlTimeout,
-		KeepAlive:       DefaultKeepAlive,
-		Timeout:         DefaultTimeout,
-		Client:          nil,
-		Logger:          nil,
-		DomainValidator: nil,
+		MaxConcurrency:      DefaultMaxConcurrency,
+		AdaptiveConcurrency: false,
+		MaxHostConcurrency:  DefaultMaxHostConcurrency,
+		MaxPendingURLS:      DefaultMaxPendingURLS,
+		BreakerThreshold:    0,
+		BreakerCooldown:     DefaultBreakerCooldown,
+		BreakerDeadline:     DefaultBreakerDeadline,
+		CrawlTimeout:        DefaultCrawlTimeout,
+		KeepAlive:           DefaultKeepAlive,
+		Timeout:             DefaultTimeout,
+		Client:              nil,
+		Logger:              nil,
+		DomainValidator:     nil,
+		PageHandler:         nil,
+		SameDomainImages:    false,
+		ImageHosts:          nil,
+		IgnoreNoFollow:      false,
+		IgnoreNoIndex:       false,
+		UserAgent:           "",
+		Headers:             nil,
+		BasicAuthUsername:   "",
+		BasicAuthPassword:   "",
+		BearerToken:         "",
+		CookieJar:           nil,
+		Cookies:             nil,
+		LoginForm:           nil,
+		Resolve:             nil,
+		DeniedNetworks:      nil,
+		AllowedNetworks:     nil,
 	}
 
 	// Options are applied first to inform client options if none is set
@@ -83,20 +138,43 @@ func NewConfig(options ...Option) *Config {
 	}
 
 	if config.Client == nil {
+		dialer := &net.Dialer{}
+		if len(config.DeniedNetworks) > 0 {
+			dialer.Control = config.controlAddress
+		}
+
+		maxConnsPerHost := config.MaxConcurrency
+		if config.MaxHostConcurrency < maxConnsPerHost {
+			maxConnsPerHost = config.MaxHostConcurrency
+		}
+
 		config.Client = &http.Client{
 			// Following redirects is disabled by default because they
 			// could redirect outside the current domain
 			CheckRedirect: overrideRedirect,
 			Transport: &http.Transport{
+				DialContext:         config.dialContext(dialer),
 				MaxIdleConns:        config.MaxConcurrency,
-				MaxIdleConnsPerHost: config.MaxConcurrency,
-				MaxConnsPerHost:     config.MaxConcurrency,
+				MaxIdleConnsPerHost: maxConnsPerHost,
+				MaxConnsPerHost:     maxConnsPerHost,
 				IdleConnTimeout:     config.KeepAlive,
 			},
 			Timeout: config.Timeout,
 		}
 	}
 
+	if config.CookieJar == nil &&
+		(len(config.Cookies) > 0 || config.LoginForm != nil) {
+		// The error is always nil
+		config.CookieJar, _ = cookiejar.New(&cookiejar.Options{
+			PublicSuffixList: publicsuffix.List,
+		})
+	}
+
+	if config.CookieJar != nil {
+		config.Client.Jar = config.CookieJar
+	}
+
 	if config.Logger == nil {
 		logger, loggerErr := zap.NewProduction(zap.IncreaseLevel(zap.WarnLevel))
 		if loggerErr != nil {
@@ -118,10 +196,26 @@ func (config *Config) Validate() error {
 		return fmt.Errorf("config.MaxConcurrency must be greater than 0")
 	}
 
+	if config.MaxHostConcurrency <= 0 {
+		return fmt.Errorf("config.MaxHostConcurrency must be greater than 0")
+	}
+
 	if config.MaxPendingURLS <= 0 {
 		return fmt.Errorf("config.MaxPendingURLS must be greater than 0")
 	}
 
+	if config.BreakerThreshold < 0 {
+		return fmt.Errorf("config.BreakerThreshold should be >= 0")
+	}
+
+	if config.BreakerThreshold > 0 && config.BreakerCooldown <= 0 {
+		return fmt.Errorf("config.BreakerCooldown must be greater than 0s")
+	}
+
+	if config.BreakerThreshold > 0 && config.BreakerDeadline <= 0 {
+		return fmt.Errorf("config.BreakerDeadline must be greater than 0s")
+	}
+
 	if config.KeepAlive < time.Duration(0) {
 		return fmt.Errorf("config.KeepAlive duration should be >= 0s")
 	}
@@ -142,6 +236,39 @@ func (config *Config) Validate() error {
 		return fmt.Errorf("config.DomainValidator must be defined")
 	}
 
+	if config.BasicAuthUsername != "" && config.BearerToken != "" {
+		return fmt.Errorf(
+			"config.BasicAuthUsername and config.BearerToken cannot both be set",
+		)
+	}
+
+	for hostPort, address := range config.Resolve {
+		if _, _, err := net.SplitHostPort(hostPort); err != nil {
+			return fmt.Errorf(
+				"config.Resolve host %q must be in the form host:port",
+				hostPort,
+			)
+		}
+
+		if net.ParseIP(address) == nil {
+			return fmt.Errorf(
+				"config.Resolve address %q must be an ip address",
+				address,
+			)
+		}
+	}
+
+	if config.LoginForm != nil {
+		loginURL, err := url.Parse(config.LoginForm.URL)
+		if err != nil || !loginURL.IsAbs() {
+			return fmt.Errorf("config.LoginForm.URL must be an absolute url")
+		}
+
+		if config.CookieJar == nil {
+			return fmt.Errorf("config.CookieJar must be defined to log in")
+		}
+	}
+
 	return nil
 }
 
@@ -165,9 +292,28 @@ func SetMaxConcurrency(maxConcurrency int) Option {
 	})
 }
 
+// SetAdaptiveConcurrency enables adaptive concurrency. The crawl starts with
+// one page at a time and grows or shrinks the number of pages crawled at once,
+// up to the maximum concurrency, based on the latency of responses and the
+// rate of 429 and 5xx responses.
+func SetAdaptiveConcurrency(adaptive bool) Option {
+	return optionFunc(func(config *Config) {
+		config.AdaptiveConcurrency = adaptive
+	})
+}
+
+// SetMaxHostConcurrency sets the number of pages of a single origin that are
+// crawled at once. This is also used to limit the connections per host of the
+// default http client.
+func SetMaxHostConcurrency(maxHostConcurrency int) Option {
+	return optionFunc(func(config *Config) {
+		config.MaxHostConcurrency = maxHostConcurrency
+	})
+}
+
 // SetMaxPendingURLS sets the maximum number of URLs that can persist in the
-// queue for crawling. This will set the size of the channel of URLs being
-// processed by the goroutines. This helps prevent cases where the number of
+// queue for crawling, across all hosts, while they wait to be processed by the
+// goroutines. This helps prevent cases where the number of
 // URLs runs away indefinitely due to dynamic urls in page links.
 func SetMaxPendingURLS(maxPendingURLS int) Option {
 	return optionFunc(func(config *Config) {
@@ -175,6 +321,32 @@ func SetMaxPendingURLS(maxPendingURLS int) Option {
 	})
 }
 
+// SetBreakerThreshold enables a circuit breaker per origin that opens after
+// the given number of consecutive requests fail with 429, 5xx or no response.
+// While the breaker is open the origin isn't crawled. When the threshold is
+// zero, the circuit breaker is disabled.
+func SetBreakerThreshold(threshold int) Option {
+	return optionFunc(func(config *Config) {
+		config.BreakerThreshold = threshold
+	})
+}
+
+// SetBreakerCooldown sets how long the crawl of an origin is paused when its
+// circuit breaker opens, before a single request probes the origin again.
+func SetBreakerCooldown(cooldown time.Duration) Option {
+	return optionFunc(func(config *Config) {
+		config.BreakerCooldown = cooldown
+	})
+}
+
+// SetBreakerDeadline sets how long an origin can keep failing probes once its
+// circuit breaker opened. The crawl is then aborted with an OriginDownError.
+func SetBreakerDeadline(deadline time.Duration) Option {
+	return optionFunc(func(config *Config) {
+		config.BreakerDeadline = deadline
+	})
+}
+
 // SetCrawlTimeout sets the maximum time spent crawling URLs. When the timeout
 // is zero or negative, no timeout is applied and the caller will wait for
 // completion. If the timeout fires, the caller will receive the partial site
@@ -236,6 +408,254 @@ func SetDomainValidator(validator DomainValidator) Option {
 	})
 }
 
+// SetPageHandler sets a handler that is called with the result of each page
+// as soon as it has been fetched, allowing results to be streamed while the
+// crawl is running. By default no handler is called.
+func SetPageHandler(handler PageHandler) Option {
+	return optionFunc(func(config *Config) {
+		config.PageHandler = handler
+	})
+}
+
+// SetSameDomainImages limits the images collected from each page to images
+// in the domain of the root, as decided by the domain validator, and images on
+// the hosts set with SetImageHosts. By default images on any host are kept.
+func SetSameDomainImages(sameDomain bool) Option {
+	return optionFunc(func(config *Config) {
+		config.SameDomainImages = sameDomain
+	})
+}
+
+// SetImageHosts sets additional hosts, such as CDNs, that images are allowed
+// on when same domain images are required.
+func SetImageHosts(hosts ...string) Option {
+	return optionFunc(func(config *Config) {
+		config.ImageHosts = hosts
+	})
+}
+
+// SetIgnoreNoFollow follows links even when they are marked rel="nofollow" or
+// the page has a nofollow robots meta tag or X-Robots-Tag header. By default
+// such links are not followed. Ignoring nofollow is useful for audit crawls.
+func SetIgnoreNoFollow(ignoreNoFollow bool) Option {
+	return optionFunc(func(config *Config) {
+		config.IgnoreNoFollow = ignoreNoFollow
+	})
+}
+
+// SetIgnoreNoIndex keeps pages with a noindex robots meta tag or X-Robots-Tag
+// header in the site map output. By default such pages are left out.
+func SetIgnoreNoIndex(ignoreNoIndex bool) Option {
+	return optionFunc(func(config *Config) {
+		config.IgnoreNoIndex = ignoreNoIndex
+	})
+}
+
+// SetUserAgent sets the User-Agent header sent with every request. When empty
+// the default user agent of the http client is used.
+func SetUserAgent(userAgent string) Option {
+	return optionFunc(func(config *Config) {
+		config.UserAgent = userAgent
+	})
+}
+
+// SetHeader adds a header that is sent with every request, such as
+// Accept-Language. The option can be repeated to add more headers or more
+// values for the same header.
+func SetHeader(name string, value string) Option {
+	return optionFunc(func(config *Config) {
+		if config.Headers == nil {
+			config.Headers = make(http.Header)
+		}
+		config.Headers.Add(name, value)
+	})
+}
+
+// SetBasicAuth sets the credentials sent with HTTP basic authentication. The
+// credentials are only sent to URLs accepted by the domain validator.
+func SetBasicAuth(username string, password string) Option {
+	return optionFunc(func(config *Config) {
+		config.BasicAuthUsername = username
+		config.BasicAuthPassword = password
+	})
+}
+
+// SetBearerToken sets a token sent as a bearer token in the Authorization
+// header. The token is only sent to URLs accepted by the domain validator.
+func SetBearerToken(token string) Option {
+	return optionFunc(func(config *Config) {
+		config.BearerToken = token
+	})
+}
+
+// SetCookieJar sets the cookie jar of the http client. The jar decides which
+// cookies are sent to each URL, based on the domain of the cookies.
+func SetCookieJar(jar http.CookieJar) Option {
+	return optionFunc(func(config *Config) {
+		config.CookieJar = jar
+	})
+}
+
+// SetCookies adds cookies to the cookie jar before crawling, such as those
+// read from a cookies.txt file with ReadNetscapeCookies. A cookie jar is
+// created if none is set. Cookies with a domain starting with a dot are sent to
+// the domain and its subdomains, cookies with any other domain are only sent
+// to that host and cookies without a domain are sent to the host of each root.
+func SetCookies(cookies ...*http.Cookie) Option {
+	return optionFunc(func(config *Config) {
+		config.Cookies = append(config.Cookies, cookies...)
+	})
+}
+
+// SetLoginForm sets a login form that is submitted before crawling, keeping
+// the session cookies in the cookie jar. A cookie jar is created if none is
+// set.
+func SetLoginForm(form *LoginForm) Option {
+	return optionFunc(func(config *Config) {
+		config.LoginForm = form
+	})
+}
+
+// SetDeniedNetworks makes the default http client refuse to connect to
+// addresses in the networks, such as those returned by PrivateNetworks. The
+// address is checked when connecting, after DNS resolution, so that neither
+// redirects nor DNS answers can reach a denied network. Pages that could not
+// be fetched are reported with an error. The networks are not checked when a
+// custom client is set.
+func SetDeniedNetworks(networks ...*net.IPNet) Option {
+	return optionFunc(func(config *Config) {
+		config.DeniedNetworks = append(config.DeniedNetworks, networks...)
+	})
+}
+
+// SetAllowedNetworks allows the default http client to connect to addresses
+// in the networks even when they are in a denied network.
+func SetAllowedNetworks(networks ...*net.IPNet) Option {
+	return optionFunc(func(config *Config) {
+		config.AllowedNetworks = append(config.AllowedNetworks, networks...)
+	})
+}
+
+// SetResolve makes the default http client connect to the ip address instead
+// of the resolved address of the host and port, like the --resolve option of
+// curl. The Host header and TLS server name of requests are unchanged, which
+// allows a new deployment to be crawled under its production hostname before
+// DNS is switched over. The option can be repeated to resolve more hosts.
+func SetResolve(hostPort string, address string) Option {
+	return optionFunc(func(config *Config) {
+		if config.Resolve == nil {
+			config.Resolve = make(map[string]string)
+		}
+		config.Resolve[strings.ToLower(hostPort)] = address
+	})
+}
+
+// dialContext returns a dial function for the default http client that
+// replaces the addresses configured in config.Resolve.
+func (config *Config) dialContext(
+	dialer *net.Dialer,
+) func(ctx context.Context, network, address string) (net.Conn, error) {
+	resolve := make(map[string]string, len(config.Resolve))
+	for hostPort, ip := range config.Resolve {
+		if _, port, err := net.SplitHostPort(hostPort); err == nil {
+			resolve[hostPort] = net.JoinHostPort(ip, port)
+		}
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if resolved, ok := resolve[strings.ToLower(address)]; ok {
+			address = resolved
+		}
+
+		return dialer.DialContext(ctx, network, address)
+	}
+}
+
+// authorization returns the value of the Authorization header for the
+// configured credentials, or an empty string if none are configured.
+func (config *Config) authorization() string {
+	if config.BearerToken != "" {
+		return "Bearer " + config.BearerToken
+	}
+
+	if config.BasicAuthUsername != "" {
+		credentials := config.BasicAuthUsername + ":" + config.BasicAuthPassword
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	return ""
+}
+
+// requestHeader returns the headers to send with every request.
+func (config *Config) requestHeader() http.Header {
+	header := make(http.Header)
+	for name, values := range config.Headers {
+		header[name] = append([]string(nil), values...)
+	}
+
+	if config.UserAgent != "" {
+		header.Set("User-Agent", config.UserAgent)
+	}
+
+	return header
+}
+
+// authRequestHeader returns the headers of requestHeader with the credentials
+// of the config. Credentials are kept in a separate header so that they are
+// only sent to URLs accepted by the domain validator.
+func (config *Config) authRequestHeader() http.Header {
+	header := config.requestHeader()
+	if authorization := config.authorization(); authorization != "" {
+		header.Set("Authorization", authorization)
+	}
+
+	return header
+}
+
+// Transport wraps the base transport so that requests made outside of the
+// crawl carry the user agent, headers and credentials of the config, as the
+// requests of the crawl do. Credentials are only sent to URLs for which
+// inScope returns true. When base is nil, http.DefaultTransport is used.
+func (config *Config) Transport(
+	base http.RoundTripper,
+	inScope func(link *url.URL) bool,
+) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &headerTransport{
+		base:       base,
+		header:     config.requestHeader(),
+		authHeader: config.authRequestHeader(),
+		inScope:    inScope,
+	}
+}
+
+// headerTransport sets headers on each request before passing it to the base
+// transport.
+type headerTransport struct {
+	base       http.RoundTripper
+	header     http.Header
+	authHeader http.Header
+	inScope    func(link *url.URL) bool
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := t.header
+	if t.inScope != nil && t.inScope(req.URL) {
+		header = t.authHeader
+	}
+
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	return t.base.RoundTrip(req)
+}
+
 // overrideRedirect is used to prevent the http client following external
 // redirects.
 func overrideRedirect(req *http.Request, via []*http.Request) error {
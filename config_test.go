@@ -22,7 +22,9 @@ package sitemapper
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
 	"time"
 
@@ -50,6 +52,55 @@ func TestValidatedMaxConcurrency(t *testing.T) {
 	}
 }
 
+func TestValidateMaxHostConcurrency(t *testing.T) {
+	expectedErr := "config.MaxHostConcurrency must be greater than 0"
+	config := NewConfig(SetMaxHostConcurrency(0))
+
+	err := config.Validate()
+
+	if err == nil {
+		t.Errorf("expected config to validate max host concurrency")
+	} else if err.Error() != expectedErr {
+		t.Errorf("expected config to validate max host concurrency: %q", err)
+	}
+}
+
+func TestValidateBreaker(t *testing.T) {
+	tests := []struct {
+		opts        []Option
+		expectedErr string
+	}{
+		{
+			[]Option{SetBreakerThreshold(-1)},
+			"config.BreakerThreshold should be >= 0",
+		},
+		{
+			[]Option{SetBreakerThreshold(3), SetBreakerCooldown(0)},
+			"config.BreakerCooldown must be greater than 0s",
+		},
+		{
+			[]Option{SetBreakerThreshold(3), SetBreakerDeadline(0)},
+			"config.BreakerDeadline must be greater than 0s",
+		},
+		{
+			[]Option{SetBreakerCooldown(0), SetBreakerDeadline(0)},
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		err := NewConfig(test.opts...).Validate()
+
+		if test.expectedErr == "" {
+			if err != nil {
+				t.Errorf("expected config to be valid: %q", err)
+			}
+		} else if err == nil || err.Error() != test.expectedErr {
+			t.Errorf("expected error %q but got %v", test.expectedErr, err)
+		}
+	}
+}
+
 func TestValidateMaxPendingURLS(t *testing.T) {
 	expectedErr := "config.MaxPendingURLS must be greater than 0"
 	config := NewConfig(SetMaxPendingURLS(0))
@@ -143,6 +194,36 @@ func TestMaxConcurrencyOption(t *testing.T) {
 	}
 }
 
+func TestAdaptiveConcurrencyOption(t *testing.T) {
+	config := NewConfig(SetAdaptiveConcurrency(true))
+
+	if !config.AdaptiveConcurrency {
+		t.Errorf("expected option to enable adaptive concurrency")
+	}
+}
+
+func TestMaxHostConcurrencyOption(t *testing.T) {
+	expectedMaxHostConcurrency := 2
+	config := NewConfig(SetMaxHostConcurrency(expectedMaxHostConcurrency))
+
+	if config.MaxHostConcurrency != expectedMaxHostConcurrency {
+		t.Errorf(
+			"expected option to set max host concurrency to %d but it was %d",
+			expectedMaxHostConcurrency,
+			config.MaxHostConcurrency,
+		)
+	}
+
+	transport := config.Client.Transport.(*http.Transport)
+	if transport.MaxConnsPerHost != expectedMaxHostConcurrency {
+		t.Errorf(
+			"expected default client to limit connections per host to %d but it was %d",
+			expectedMaxHostConcurrency,
+			transport.MaxConnsPerHost,
+		)
+	}
+}
+
 func TestMaxPendingURLSOption(t *testing.T) {
 	expectedMaxPendingURLS := DefaultMaxPendingURLS * 2
 	config := NewConfig(SetMaxPendingURLS(expectedMaxPendingURLS))
@@ -156,6 +237,25 @@ func TestMaxPendingURLSOption(t *testing.T) {
 	}
 }
 
+func TestBreakerOptions(t *testing.T) {
+	config := NewConfig(
+		SetBreakerThreshold(5),
+		SetBreakerCooldown(time.Minute),
+		SetBreakerDeadline(time.Hour),
+	)
+
+	if config.BreakerThreshold != 5 ||
+		config.BreakerCooldown != time.Minute ||
+		config.BreakerDeadline != time.Hour {
+		t.Errorf(
+			"expected options to set the breaker to 5, 1m0s, 1h0m0s but got %d, %s, %s",
+			config.BreakerThreshold,
+			config.BreakerCooldown,
+			config.BreakerDeadline,
+		)
+	}
+}
+
 func TestCrawlTimeoutOption(t *testing.T) {
 	expectedCrawlTimeout := 5 * time.Second
 	config := NewConfig(SetCrawlTimeout(expectedCrawlTimeout))
@@ -248,6 +348,231 @@ func TestDomainValidatorOption(t *testing.T) {
 	}
 }
 
+func TestPageHandlerOption(t *testing.T) {
+	var handled []Page
+	handler := PageHandlerFunc(func(page Page) {
+		handled = append(handled, page)
+	})
+
+	config := NewConfig(SetPageHandler(handler))
+
+	config.PageHandler.HandlePage(Page{URL: "http://example.com"})
+	if len(handled) != 1 {
+		t.Errorf("expected config to use provided page handler")
+	}
+}
+
+func TestImageOptions(t *testing.T) {
+	config := NewConfig(
+		SetSameDomainImages(true),
+		SetImageHosts("cdn.example.com"),
+	)
+
+	if !config.SameDomainImages {
+		t.Errorf("expected option to require same domain images")
+	}
+
+	expectedHosts := []string{"cdn.example.com"}
+	if !reflect.DeepEqual(config.ImageHosts, expectedHosts) {
+		t.Errorf(
+			"expected option to set image hosts but got %v",
+			config.ImageHosts,
+		)
+	}
+}
+
+func TestRobotsOptions(t *testing.T) {
+	config := NewConfig()
+
+	if config.IgnoreNoFollow || config.IgnoreNoIndex {
+		t.Errorf("expected robots directives to be respected by default")
+	}
+
+	config = NewConfig(SetIgnoreNoFollow(true), SetIgnoreNoIndex(true))
+
+	if !config.IgnoreNoFollow {
+		t.Errorf("expected option to ignore nofollow directives")
+	}
+
+	if !config.IgnoreNoIndex {
+		t.Errorf("expected option to ignore noindex directives")
+	}
+}
+
+func TestHeaderOptions(t *testing.T) {
+	config := NewConfig(
+		SetUserAgent("sitemapper/1.0"),
+		SetHeader("Accept-Language", "en"),
+		SetHeader("Accept-Language", "fr"),
+	)
+
+	if config.UserAgent != "sitemapper/1.0" {
+		t.Errorf("expected option to set user agent but got %q", config.UserAgent)
+	}
+
+	header := config.requestHeader()
+	expectedLanguages := []string{"en", "fr"}
+	if !reflect.DeepEqual(header["Accept-Language"], expectedLanguages) {
+		t.Errorf(
+			"expected request header to have languages %v but got %v",
+			expectedLanguages,
+			header["Accept-Language"],
+		)
+	}
+
+	if header.Get("User-Agent") != "sitemapper/1.0" {
+		t.Errorf("expected request header to have the user agent")
+	}
+
+	if len(NewConfig().requestHeader()) != 0 {
+		t.Errorf("expected no request headers by default")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var received []http.Header
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			received = append(received, r.Header)
+		},
+	))
+	defer testServer.Close()
+
+	config := NewConfig(
+		SetUserAgent("sitemapper-test"),
+		SetHeader("X-Preview", "1"),
+		SetBearerToken("secret"),
+	)
+
+	client := *config.Client
+	client.Transport = config.Transport(
+		client.Transport,
+		func(link *url.URL) bool {
+			return link.Path == "/private"
+		},
+	)
+
+	for _, path := range []string{"/private", "/public"} {
+		req, _ := http.NewRequest(http.MethodGet, testServer.URL+path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("error requesting %s: %q", path, err)
+		}
+		resp.Body.Close()
+
+		if len(req.Header) != 0 {
+			t.Errorf("expected the request not to be modified: %v", req.Header)
+		}
+	}
+
+	for i, header := range received {
+		if header.Get("User-Agent") != "sitemapper-test" ||
+			header.Get("X-Preview") != "1" {
+			t.Errorf("expected config headers on request %d: %v", i, header)
+		}
+	}
+
+	if received[0].Get("Authorization") != "Bearer secret" {
+		t.Errorf("expected credentials for the url in scope")
+	}
+
+	if received[1].Get("Authorization") != "" {
+		t.Errorf("expected no credentials for the url out of scope")
+	}
+}
+
+func TestAuthOptions(t *testing.T) {
+	config := NewConfig(SetBasicAuth("user", "secret"))
+	if config.authorization() != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Errorf("expected basic auth but got %q", config.authorization())
+	}
+
+	config = NewConfig(SetBearerToken("token"))
+	if config.authorization() != "Bearer token" {
+		t.Errorf("expected bearer token but got %q", config.authorization())
+	}
+
+	if NewConfig().authorization() != "" {
+		t.Errorf("expected no credentials by default")
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	expectedErr := "config.BasicAuthUsername and config.BearerToken " +
+		"cannot both be set"
+	config := NewConfig(SetBasicAuth("user", "secret"), SetBearerToken("a"))
+
+	err := config.Validate()
+
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected config to validate credentials: %q", err)
+	}
+}
+
+func TestCookieJarOption(t *testing.T) {
+	if NewConfig().Client.Jar != nil {
+		t.Errorf("expected no cookie jar by default")
+	}
+
+	jar := NewConfig(SetCookies(&http.Cookie{Name: "a"})).CookieJar
+	if jar == nil {
+		t.Fatalf("expected a cookie jar to be created for cookies")
+	}
+
+	config := NewConfig(SetClient(http.DefaultClient), SetCookieJar(jar))
+	if config.Client.Jar != jar {
+		t.Errorf("expected the client to use the cookie jar")
+	}
+
+	if http.DefaultClient.Jar != nil {
+		t.Errorf("expected the provided client not to be mutated")
+	}
+}
+
+func TestValidateLoginForm(t *testing.T) {
+	expectedErr := "config.LoginForm.URL must be an absolute url"
+	config := NewConfig(SetLoginForm(&LoginForm{URL: "/login"}))
+
+	err := config.Validate()
+
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected config to validate login form: %q", err)
+	}
+
+	if config.Client.Jar == nil {
+		t.Errorf("expected a cookie jar to be created for the login form")
+	}
+}
+
+func TestValidateResolve(t *testing.T) {
+	tests := []struct {
+		hostPort    string
+		address     string
+		expectedErr string
+	}{
+		{
+			"www.example.com",
+			"127.0.0.1",
+			`config.Resolve host "www.example.com" must be in the form host:port`,
+		},
+		{
+			"www.example.com:443",
+			"localhost",
+			`config.Resolve address "localhost" must be an ip address`,
+		},
+	}
+
+	for _, test := range tests {
+		config := NewConfig(SetResolve(test.hostPort, test.address))
+
+		err := config.Validate()
+
+		if err == nil || err.Error() != test.expectedErr {
+			t.Errorf("expected config to validate resolve: %q", err)
+		}
+	}
+}
+
 func TestClienNilOption(t *testing.T) {
 	config := NewConfig(SetClient(nil))
 
@@ -0,0 +1,168 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in a Netscape cookies.txt file.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ReadNetscapeCookies reads cookies from a file in the Netscape cookies.txt
+// format, as exported by browsers and written by curl. Each line holds the
+// tab separated domain, subdomain flag, path, secure flag, expiry time, name
+// and value of a cookie. The domain of cookies that apply to subdomains is
+// given a leading dot, as expected by SetCookies.
+func ReadNetscapeCookies(in io.Reader) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		} else if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		cookie, err := parseNetscapeCookie(line)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"invalid cookie on line %d: %s",
+				lineNumber,
+				err,
+			)
+		}
+
+		cookie.HttpOnly = httpOnly
+		cookies = append(cookies, cookie)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cookies, nil
+}
+
+func parseNetscapeCookie(line string) (*http.Cookie, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("expected 7 tab separated fields but got %d", len(fields))
+	}
+
+	includeSubdomains, err := parseCookieFlag(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	secure, err := parseCookieFlag(fields[3])
+	if err != nil {
+		return nil, err
+	}
+
+	expires, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiry time %q", fields[4])
+	}
+
+	domain := strings.TrimPrefix(fields[0], ".")
+	if domain == "" {
+		return nil, fmt.Errorf("missing cookie domain")
+	}
+	if includeSubdomains {
+		domain = "." + domain
+	}
+
+	cookie := &http.Cookie{
+		Domain: domain,
+		Path:   fields[2],
+		Secure: secure,
+		Name:   fields[5],
+		Value:  fields[6],
+	}
+
+	// An expiry time of 0 is used for session cookies
+	if expires > 0 {
+		cookie.Expires = time.Unix(expires, 0).UTC()
+	}
+
+	return cookie, nil
+}
+
+func parseCookieFlag(flag string) (bool, error) {
+	switch flag {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected TRUE or FALSE but got %q", flag)
+	}
+}
+
+// setCookies adds the cookies to the jar. Cookies with a domain starting with
+// a dot are domain cookies, cookies with any other domain are host-only cookies
+// for that host and cookies without a domain are host-only cookies for the
+// host of each root.
+func setCookies(jar http.CookieJar, roots []*url.URL, cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		if cookie.Domain != "" {
+			host := strings.TrimPrefix(cookie.Domain, ".")
+			setCookie(jar, host, cookie)
+			continue
+		}
+
+		for _, root := range roots {
+			setCookie(jar, root.Host, cookie)
+		}
+	}
+}
+
+// setCookie adds the cookie to the jar for the host.
+func setCookie(jar http.CookieJar, host string, cookie *http.Cookie) {
+	cookieCopy := *cookie
+	cookieURL := &url.URL{Scheme: "http", Host: host, Path: "/"}
+
+	if !strings.HasPrefix(cookie.Domain, ".") {
+		cookieCopy.Domain = ""
+	}
+
+	if cookie.Secure {
+		cookieURL.Scheme = "https"
+	}
+
+	if cookie.Path != "" {
+		cookieURL.Path = cookie.Path
+	}
+
+	jar.SetCookies(cookieURL, []*http.Cookie{&cookieCopy})
+}
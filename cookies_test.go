@@ -0,0 +1,206 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestReadNetscapeCookies(t *testing.T) {
+	cookies, err := ReadNetscapeCookies(strings.NewReader(
+		"# Netscape HTTP Cookie File\n" +
+			"\n" +
+			".example.com\tTRUE\t/\tFALSE\t0\tsession\tabc\n" +
+			"#HttpOnly_staging.example.com\tFALSE\t/app\tTRUE\t1893456000\tauth\tx=y\n",
+	))
+	if err != nil {
+		t.Fatalf("error reading cookies: %q", err)
+	}
+
+	expected := []*http.Cookie{
+		{
+			Domain: ".example.com",
+			Path:   "/",
+			Name:   "session",
+			Value:  "abc",
+		},
+		{
+			Domain:   "staging.example.com",
+			Path:     "/app",
+			Secure:   true,
+			HttpOnly: true,
+			Expires:  time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			Name:     "auth",
+			Value:    "x=y",
+		},
+	}
+
+	if !reflect.DeepEqual(cookies, expected) {
+		t.Errorf("expected cookies %v but got %v", expected, cookies)
+	}
+}
+
+func TestReadNetscapeCookiesInvalid(t *testing.T) {
+	tests := []struct {
+		cookies     string
+		expectedErr string
+	}{
+		{
+			"example.com\tTRUE\t/\n",
+			"invalid cookie on line 1: expected 7 tab separated fields but got 3",
+		},
+		{
+			"# comment\nexample.com\tyes\t/\tFALSE\t0\ta\tb\n",
+			`invalid cookie on line 2: expected TRUE or FALSE but got "yes"`,
+		},
+		{
+			"example.com\tTRUE\t/\tFALSE\tnever\ta\tb\n",
+			`invalid cookie on line 1: invalid expiry time "never"`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := ReadNetscapeCookies(strings.NewReader(test.cookies))
+		if err == nil || err.Error() != test.expectedErr {
+			t.Errorf("expected error %q but got %q", test.expectedErr, err)
+		}
+	}
+}
+
+func TestSetCookies(t *testing.T) {
+	config := NewConfig(SetCookies(
+		&http.Cookie{Domain: ".example.com", Name: "domain", Value: "1"},
+		&http.Cookie{Domain: "www.example.com", Name: "host", Value: "2"},
+		&http.Cookie{Name: "root", Value: "3"},
+	))
+	root, _ := url.Parse("http://example.com")
+	otherRoot, _ := url.Parse("http://docs.example.org")
+	setCookies(config.CookieJar, []*url.URL{root, otherRoot}, config.Cookies)
+
+	tests := []struct {
+		url      string
+		expected []string
+	}{
+		{"http://example.com/", []string{"domain", "root"}},
+		{"http://www.example.com/", []string{"domain", "host"}},
+		{"http://sub.www.example.com/", []string{"domain"}},
+		{"http://docs.example.org/", []string{"root"}},
+		{"http://other.com/", nil},
+	}
+
+	for _, test := range tests {
+		cookieURL, _ := url.Parse(test.url)
+
+		var names []string
+		for _, cookie := range config.CookieJar.Cookies(cookieURL) {
+			names = append(names, cookie.Name)
+		}
+
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf(
+				"expected cookies %v for %s but got %v",
+				test.expected,
+				test.url,
+				names,
+			)
+		}
+	}
+}
+
+func TestCrawlWithCookies(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if cookie, err := r.Cookie("session"); err != nil ||
+				cookie.Value != "abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/private">private</a>`))
+		},
+	))
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+		SetCookies(&http.Cookie{Name: "session", Value: "abc"}),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	pages := sitemap.Pages()
+	if len(pages) != 2 || pages[1].StatusCode != http.StatusOK {
+		t.Errorf("expected the private page to be crawled but got %v", pages)
+	}
+}
+
+func TestCrawlRootsWithCookies(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil ||
+			cookie.Value != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<a href="/private">private</a>`))
+	})
+
+	first := httptest.NewServer(handler)
+	defer first.Close()
+
+	second := httptest.NewServer(handler)
+	defer second.Close()
+
+	// The cookie jar ignores ports, so the roots use different host names
+	secondURL, _ := url.Parse(second.URL)
+	secondRoot := "http://localhost:" + secondURL.Port()
+
+	sitemap, err := CrawlDomains(
+		[]string{first.URL, secondRoot},
+		SetLogger(zap.NewNop()),
+		SetCookies(&http.Cookie{Name: "session", Value: "abc"}),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	pages := sitemap.Pages()
+	if len(pages) != 4 {
+		t.Fatalf("expected both roots and their private pages but got %v", pages)
+	}
+
+	for _, page := range pages {
+		if page.StatusCode != http.StatusOK {
+			t.Errorf("expected the session cookie to be sent to %s", page.URL)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSV column names accepted by WriteCSV.
+const (
+	CSVColumnURL          = "url"
+	CSVColumnStatus       = "status"
+	CSVColumnContentType  = "content_type"
+	CSVColumnSize         = "size"
+	CSVColumnResponseTime = "response_time_ms"
+	CSVColumnDepth        = "depth"
+	CSVColumnInlinks      = "inlinks"
+	CSVColumnTitle        = "title"
+	CSVColumnCanonical    = "canonical"
+)
+
+// DefaultCSVColumns are the columns written by WriteCSV when none are given.
+var DefaultCSVColumns = []string{
+	CSVColumnURL,
+	CSVColumnStatus,
+	CSVColumnContentType,
+	CSVColumnSize,
+	CSVColumnResponseTime,
+	CSVColumnDepth,
+	CSVColumnInlinks,
+	CSVColumnTitle,
+	CSVColumnCanonical,
+}
+
+// csvColumnValues maps each column name to a function that formats the value
+// of the column for a page.
+var csvColumnValues = map[string]func(page *Page) string{
+	CSVColumnURL: func(page *Page) string {
+		return page.URL
+	},
+	CSVColumnStatus: func(page *Page) string {
+		if page.StatusCode == 0 {
+			return ""
+		}
+		return strconv.Itoa(page.StatusCode)
+	},
+	CSVColumnContentType: func(page *Page) string {
+		return page.ContentType
+	},
+	CSVColumnSize: func(page *Page) string {
+		return strconv.FormatInt(page.Size, 10)
+	},
+	CSVColumnResponseTime: func(page *Page) string {
+		return strconv.FormatInt(page.Duration.Milliseconds(), 10)
+	},
+	CSVColumnDepth: func(page *Page) string {
+		return strconv.Itoa(page.Depth)
+	},
+	CSVColumnInlinks: func(page *Page) string {
+		return strconv.Itoa(len(page.Inlinks))
+	},
+	CSVColumnTitle: func(page *Page) string {
+		return page.Title
+	},
+	CSVColumnCanonical: func(page *Page) string {
+		return page.Canonical
+	},
+}
+
+// ParseCSVColumns parses a comma separated list of CSV column names.
+func ParseCSVColumns(columns string) ([]string, error) {
+	parsed := strings.Split(columns, ",")
+	for i, column := range parsed {
+		parsed[i] = strings.TrimSpace(column)
+		if csvColumnValues[parsed[i]] == nil {
+			return nil, fmt.Errorf("unknown csv column %q", parsed[i])
+		}
+	}
+
+	return parsed, nil
+}
+
+// WriteCSV writes one row per URL in the site map to the writer, preceded by
+// a header row of column names. When no columns are given DefaultCSVColumns
+// are written. Fields are quoted as specified by RFC 4180.
+func (s *SiteMap) WriteCSV(out io.Writer, columns ...string) error {
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+
+	values := make([]func(page *Page) string, len(columns))
+	for i, column := range columns {
+		values[i] = csvColumnValues[column]
+		if values[i] == nil {
+			return fmt.Errorf("unknown csv column %q", column)
+		}
+	}
+
+	writer := csv.NewWriter(out)
+	writer.UseCRLF = true
+
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for _, page := range s.Pages() {
+		for i, value := range values {
+			record[i] = value(&page)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestWriteCSV(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error reading example site map: %q", err)
+	}
+
+	var out bytes.Buffer
+	err = sitemap.WriteCSV(
+		&out,
+		CSVColumnURL,
+		CSVColumnStatus,
+		CSVColumnInlinks,
+		CSVColumnTitle,
+		CSVColumnCanonical,
+	)
+	if err != nil {
+		t.Fatalf("error writing csv: %q", err)
+	}
+
+	expectedHeader := "url,status,inlinks,title,canonical\r\n"
+	if !strings.HasPrefix(out.String(), expectedHeader) {
+		t.Errorf("expected header row with CRLF but got:\n\n%s", out.String())
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("error reading csv: %q", err)
+	}
+
+	if len(records) != len(expectedSiteMap)+1 {
+		t.Fatalf(
+			"expected %d records but got %d",
+			len(expectedSiteMap)+1,
+			len(records),
+		)
+	}
+
+	// The root and "/" are separate pages that both link to /secret, and
+	// every page except /about itself links to /about in the navigation
+	aboutURL := testServer.URL + "/about"
+	hiddenURL := testServer.URL + "/hidden"
+	expectedRecords := map[string][]string{
+		"/about":      {"200", "7", "About Us", aboutURL},
+		"/hidden?t=0": {"200", "1", "Hidden & Secret", hiddenURL},
+		"/secret":     {"301", "2", "", ""},
+	}
+
+	for _, record := range records[1:] {
+		path := strings.TrimPrefix(record[0], testServer.URL)
+		expected, ok := expectedRecords[path]
+		if !ok {
+			continue
+		}
+
+		if strings.Join(record[1:], "|") != strings.Join(expected, "|") {
+			t.Errorf(
+				"expected record %v for %s but got %v",
+				expected,
+				path,
+				record[1:],
+			)
+		}
+	}
+}
+
+func TestWriteCSVQuoting(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/", "title": "Say \"hi\", world"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	var out bytes.Buffer
+	if err := sitemap.WriteCSV(&out, CSVColumnURL, CSVColumnTitle); err != nil {
+		t.Fatalf("error writing csv: %q", err)
+	}
+
+	expected := "url,title\r\n" +
+		"http://example.com/,\"Say \"\"hi\"\", world\"\r\n"
+
+	if out.String() != expected {
+		t.Errorf("expected csv:\n\n%q\n\nGot:\n\n%q", expected, out.String())
+	}
+}
+
+func TestParseCSVColumns(t *testing.T) {
+	columns, err := ParseCSVColumns("url, status,title")
+	if err != nil {
+		t.Fatalf("error parsing columns: %q", err)
+	}
+
+	if strings.Join(columns, ",") != "url,status,title" {
+		t.Errorf("unexpected columns %v", columns)
+	}
+
+	expectedErr := `unknown csv column "bogus"`
+	if _, err := ParseCSVColumns("url,bogus"); err == nil ||
+		err.Error() != expectedErr {
+		t.Errorf("expected unknown column error but got %q", err)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SiteMapDiff describes the differences between two crawls of a site. Added
+// URLs only appear in the current crawl, removed URLs only appear in the
+// previous crawl and changed URLs appear in both with a different status.
+type SiteMapDiff struct {
+	Added   []string       `json:"added"`
+	Removed []string       `json:"removed"`
+	Changed []StatusChange `json:"changed"`
+}
+
+// StatusChange records a URL whose status differs between two crawls.
+type StatusChange struct {
+	URL       string `json:"url"`
+	OldStatus int    `json:"oldStatus"`
+	NewStatus int    `json:"newStatus"`
+}
+
+// DiffSiteMaps compares a previous crawl with the current crawl. All lists in
+// the result are ordered by URL.
+func DiffSiteMaps(previous, current *SiteMap) *SiteMapDiff {
+	diff := &SiteMapDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []StatusChange{},
+	}
+
+	previousPages := previous.Pages()
+	currentPages := current.Pages()
+
+	// Both page lists are sorted by URL so they can be merged in one pass
+	i, j := 0, 0
+	for i < len(previousPages) || j < len(currentPages) {
+		switch {
+		case j == len(currentPages) ||
+			(i < len(previousPages) && previousPages[i].URL < currentPages[j].URL):
+			diff.Removed = append(diff.Removed, previousPages[i].URL)
+			i++
+		case i == len(previousPages) ||
+			currentPages[j].URL < previousPages[i].URL:
+			diff.Added = append(diff.Added, currentPages[j].URL)
+			j++
+		default:
+			if previousPages[i].StatusCode != currentPages[j].StatusCode {
+				diff.Changed = append(diff.Changed, StatusChange{
+					URL:       currentPages[j].URL,
+					OldStatus: previousPages[i].StatusCode,
+					NewStatus: currentPages[j].StatusCode,
+				})
+			}
+			i++
+			j++
+		}
+	}
+
+	return diff
+}
+
+// Empty returns true if the crawls did not differ.
+func (d *SiteMapDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// WriteText writes a human readable version of the diff to the writer.
+func (d *SiteMapDiff) WriteText(out io.Writer) error {
+	if _, err := fmt.Fprintf(out, "Added: %d\n", len(d.Added)); err != nil {
+		return err
+	}
+	for _, u := range d.Added {
+		if _, err := fmt.Fprintf(out, "  + %s\n", u); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(out, "\nRemoved: %d\n", len(d.Removed)); err != nil {
+		return err
+	}
+	for _, u := range d.Removed {
+		if _, err := fmt.Fprintf(out, "  - %s\n", u); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(out, "\nChanged: %d\n", len(d.Changed)); err != nil {
+		return err
+	}
+	for _, change := range d.Changed {
+		_, err := fmt.Fprintf(out, "  ~ %s %s -> %s\n",
+			change.URL,
+			statusString(change.OldStatus),
+			statusString(change.NewStatus),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the diff as a JSON object to the writer.
+func (d *SiteMapDiff) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+// statusString formats a status code for display. A status of 0 means that no
+// response was received.
+func statusString(statusCode int) string {
+	if statusCode == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%d", statusCode)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffSiteMaps(t *testing.T) {
+	previous, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/a", "status": 200},
+			{"url": "http://example.com/b", "status": 200},
+			{"url": "http://example.com/c", "status": 200},
+			{"url": "http://example.com/e"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading previous snapshot: %q", err)
+	}
+
+	current, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/b", "status": 404},
+			{"url": "http://example.com/c", "status": 200},
+			{"url": "http://example.com/d", "status": 200},
+			{"url": "http://example.com/e", "status": 500}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading current snapshot: %q", err)
+	}
+
+	diff := DiffSiteMaps(previous, current)
+
+	expected := &SiteMapDiff{
+		Added:   []string{"http://example.com/d"},
+		Removed: []string{"http://example.com/a"},
+		Changed: []StatusChange{
+			{URL: "http://example.com/b", OldStatus: 200, NewStatus: 404},
+			{URL: "http://example.com/e", OldStatus: 0, NewStatus: 500},
+		},
+	}
+
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("unexpected diff.\n\nGot:\n\n%v\n\nExpected:\n\n%v", diff, expected)
+	}
+
+	var text bytes.Buffer
+	if err := diff.WriteText(&text); err != nil {
+		t.Fatalf("error writing diff: %q", err)
+	}
+
+	expectedText := "Added: 1\n" +
+		"  + http://example.com/d\n" +
+		"\nRemoved: 1\n" +
+		"  - http://example.com/a\n" +
+		"\nChanged: 2\n" +
+		"  ~ http://example.com/b 200 -> 404\n" +
+		"  ~ http://example.com/e none -> 500\n"
+
+	if text.String() != expectedText {
+		t.Errorf("unexpected text diff.\n\nGot:\n\n%s", text.String())
+	}
+
+	if !DiffSiteMaps(current, current).Empty() {
+		t.Errorf("expected a site map to be equal to itself")
+	}
+}
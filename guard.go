@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// privateNetworks are the loopback, link-local, private and other special
+// purpose networks that should not be reachable from user submitted URLs.
+var privateNetworks = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// PrivateNetworks returns the loopback, link-local and private networks,
+// including the RFC 1918 ranges, for use with SetDeniedNetworks.
+func PrivateNetworks() []*net.IPNet {
+	networks, err := ParseNetworks(privateNetworks...)
+	if err != nil {
+		panic(err)
+	}
+
+	return networks
+}
+
+// ParseNetworks parses a list of networks in CIDR notation, such as
+// 10.0.0.0/8.
+func ParseNetworks(cidrs ...string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// BlockedAddressError is returned when the default http client refuses to
+// connect to an address in a denied network.
+type BlockedAddressError struct {
+	Address string
+}
+
+func (e *BlockedAddressError) Error() string {
+	return fmt.Sprintf("address %s is in a denied network", e.Address)
+}
+
+// controlAddress is used as the control function of the dialer of the default
+// http client. It is called with the resolved address of each connection, so
+// hosts that resolve to a denied network are blocked whatever their name.
+func (config *Config) controlAddress(
+	network string,
+	address string,
+	conn syscall.RawConn,
+) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &BlockedAddressError{Address: host}
+	}
+
+	if containsIP(config.AllowedNetworks, ip) {
+		return nil
+	}
+
+	if containsIP(config.DeniedNetworks, ip) {
+		return &BlockedAddressError{Address: ip.String()}
+	}
+
+	return nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDeniedNetworks(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `<a href="/page">page</a>`)
+		},
+	))
+	defer testServer.Close()
+
+	serverURL, _ := url.Parse(testServer.URL)
+	localhostURL := "http://localhost:" + serverURL.Port()
+
+	// localhost resolves to a loopback address, which is checked on connect
+	_, err := CrawlDomain(
+		localhostURL,
+		SetLogger(zap.NewNop()),
+		SetDeniedNetworks(PrivateNetworks()...),
+	)
+
+	expectedErr := fmt.Sprintf("unable to access url %s: ", localhostURL)
+	if err == nil || !strings.HasPrefix(err.Error(), expectedErr) {
+		t.Fatalf("expected error %q but got %q", expectedErr, err)
+	}
+
+	expectedCause := "is in a denied network"
+	if !strings.Contains(err.Error(), expectedCause) {
+		t.Errorf("expected error to contain %q but got %q", expectedCause, err)
+	}
+
+	allowed, _ := ParseNetworks("127.0.0.1/32", "::1/128")
+	sitemap, err := CrawlDomain(
+		localhostURL,
+		SetLogger(zap.NewNop()),
+		SetDeniedNetworks(PrivateNetworks()...),
+		SetAllowedNetworks(allowed...),
+	)
+	if err != nil {
+		t.Fatalf("expected allowed networks to be crawled but got %q", err)
+	}
+
+	if len(sitemap.Pages()) != 2 || sitemap.Pages()[1].StatusCode != 200 {
+		t.Errorf("expected the page to be crawled but got %v", sitemap.Pages())
+	}
+}
+
+func TestDeniedNetworksReportsErrors(t *testing.T) {
+	testServer := httptest.NewServer(nil)
+	defer testServer.Close()
+
+	serverURL, _ := url.Parse(testServer.URL)
+	blockedURL := "http://127.0.0.2:" + serverURL.Port() + "/admin"
+
+	testServer.Config.Handler = http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `<a href="`+blockedURL+`">admin</a>`)
+		},
+	)
+
+	denied, _ := ParseNetworks("127.0.0.0/8")
+	allowed, _ := ParseNetworks("127.0.0.1/32")
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetLogger(zap.NewNop()),
+		SetDomainValidator(DomainValidatorFunc(func(root, link *url.URL) bool {
+			return true
+		})),
+		SetDeniedNetworks(denied...),
+		SetAllowedNetworks(allowed...),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	pages := sitemap.Pages()
+	if len(pages) != 2 || pages[1].URL != blockedURL {
+		t.Fatalf("expected the root and the blocked page but got %v", pages)
+	}
+
+	expectedErr := "address 127.0.0.2 is in a denied network"
+	if !strings.Contains(pages[1].Error, expectedErr) {
+		t.Errorf(
+			"expected blocked page error %q but got %q",
+			expectedErr,
+			pages[1].Error,
+		)
+	}
+}
+
+func TestPrivateNetworks(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:2800:220:1::1", false},
+	}
+
+	networks := PrivateNetworks()
+	for _, test := range tests {
+		if containsIP(networks, net.ParseIP(test.ip)) != test.private {
+			t.Errorf("expected %s private to be %v", test.ip, test.private)
+		}
+	}
+}
+
+func TestParseNetworksInvalid(t *testing.T) {
+	if _, err := ParseNetworks("10.0.0.0/8", "10.0.0.0"); err == nil {
+		t.Errorf("expected an error for a network without a prefix length")
+	}
+}
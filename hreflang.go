@@ -0,0 +1,167 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// Reasons for an hreflang alternate to be reported by ValidateAlternates.
+const (
+	AlternateNotCrawled   = "alternate not crawled"
+	AlternateNoReturnLink = "no return link"
+	AlternateOffDomain    = "alternate off domain"
+)
+
+// MissingAlternate is an hreflang alternate declared by a page that is not
+// reciprocated by the alternate page, or that could not be checked.
+type MissingAlternate struct {
+	URL       string `json:"url"`
+	Hreflang  string `json:"hreflang"`
+	Alternate string `json:"alternate"`
+	Reason    string `json:"reason"`
+}
+
+// HreflangReport lists the hreflang alternates which are not reciprocal.
+// Alternates outside of the domain are not crawled, so their reciprocity is
+// unknown and they are listed as unverified.
+type HreflangReport struct {
+	Missing    []MissingAlternate `json:"missing"`
+	Unverified []MissingAlternate `json:"unverified"`
+}
+
+// ValidateAlternates checks that the hreflang alternates of each page are
+// reciprocal. Search engines ignore an alternate unless the alternate page
+// links back to the page that declared it. An alternate is reported when the
+// alternate page was not successfully crawled or does not declare the page as
+// one of its own alternates. Alternates outside of the domain, as decided by
+// the domain validator, are reported as unverified.
+func (s *SiteMap) ValidateAlternates() *HreflangReport {
+	pages := s.Pages()
+
+	byURL := make(map[string]*Page, len(pages))
+	for i := range pages {
+		byURL[pages[i].URL] = &pages[i]
+	}
+
+	report := &HreflangReport{
+		Missing:    []MissingAlternate{},
+		Unverified: []MissingAlternate{},
+	}
+
+	for _, page := range pages {
+		for _, alternate := range page.Alternates {
+			if alternate.URL == page.URL {
+				continue
+			}
+
+			alternateURL, err := url.Parse(alternate.URL)
+			if err != nil || !s.inScope(alternateURL) {
+				report.Unverified = append(report.Unverified, MissingAlternate{
+					URL:       page.URL,
+					Hreflang:  alternate.Hreflang,
+					Alternate: alternate.URL,
+					Reason:    AlternateOffDomain,
+				})
+				continue
+			}
+
+			reason := ""
+			alternatePage := byURL[alternate.URL]
+			switch {
+			case alternatePage == nil || !isSitemapPage(alternatePage) ||
+				alternatePage.StatusCode == 0:
+				reason = AlternateNotCrawled
+			case !hasAlternate(alternatePage, page.URL):
+				reason = AlternateNoReturnLink
+			default:
+				continue
+			}
+
+			report.Missing = append(report.Missing, MissingAlternate{
+				URL:       page.URL,
+				Hreflang:  alternate.Hreflang,
+				Alternate: alternate.URL,
+				Reason:    reason,
+			})
+		}
+	}
+
+	return report
+}
+
+// hasAlternate returns true if the page declares the URL as an alternate.
+func hasAlternate(page *Page, alternateURL string) bool {
+	for _, alternate := range page.Alternates {
+		if alternate.URL == alternateURL {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes a human readable version of the report to the writer.
+func (r *HreflangReport) WriteText(out io.Writer) error {
+	sections := []struct {
+		title      string
+		alternates []MissingAlternate
+	}{
+		{"Missing alternates", r.Missing},
+		{"Unverified alternates", r.Unverified},
+	}
+
+	for i, section := range sections {
+		if i > 0 {
+			if _, err := io.WriteString(out, "\n"); err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(out, "%s: %d\n", section.title, len(section.alternates))
+		if err != nil {
+			return err
+		}
+
+		for _, alternate := range section.alternates {
+			_, err := fmt.Fprintf(out, "  %s -> %s (%s): %s\n",
+				alternate.URL,
+				alternate.Alternate,
+				alternate.Hreflang,
+				alternate.Reason,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as a JSON object to the writer.
+func (r *HreflangReport) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
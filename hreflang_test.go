@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCrawlAlternates(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error reading example site map: %q", err)
+	}
+
+	expected := []Alternate{
+		{Hreflang: "en", URL: testServer.URL + "/about"},
+		{Hreflang: "fr", URL: testServer.URL + "/fr/about"},
+	}
+
+	for _, page := range sitemap.Pages() {
+		if page.URL != testServer.URL+"/about" {
+			continue
+		}
+
+		if !reflect.DeepEqual(page.Alternates, expected) {
+			t.Errorf(
+				"expected alternates %v but got %v",
+				expected,
+				page.Alternates,
+			)
+		}
+	}
+
+	// The french page is only declared as an alternate and links back
+	report := sitemap.ValidateAlternates()
+	if len(report.Missing) != 0 || len(report.Unverified) != 0 {
+		t.Errorf("expected reciprocal alternates but got %v", report)
+	}
+}
+
+func TestValidateAlternates(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/en", "status": 200, "alternates": [
+				{"hreflang": "en", "url": "http://example.com/en"},
+				{"hreflang": "de", "url": "http://example.com/de"},
+				{"hreflang": "fr", "url": "http://example.com/fr"},
+				{"hreflang": "es", "url": "http://example.com/es"},
+				{"hreflang": "de-CH", "url": "http://example.ch/de"}
+			]},
+			{"url": "http://example.com/de", "status": 200, "alternates": [
+				{"hreflang": "en", "url": "http://example.com/en"}
+			]},
+			{"url": "http://example.com/fr", "status": 200},
+			{"url": "http://example.com/es", "status": 404}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	report := sitemap.ValidateAlternates()
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatalf("error writing report: %q", err)
+	}
+
+	expected := "Missing alternates: 2\n" +
+		"  http://example.com/en -> http://example.com/fr (fr): " +
+		"no return link\n" +
+		"  http://example.com/en -> http://example.com/es (es): " +
+		"alternate not crawled\n" +
+		"\n" +
+		"Unverified alternates: 1\n" +
+		"  http://example.com/en -> http://example.ch/de (de-CH): " +
+		"alternate off domain\n"
+
+	if out.String() != expected {
+		t.Errorf(
+			"unexpected report.\n\nGot:\n\n%s\n\nExpected:\n\n%s",
+			out.String(),
+			expected,
+		)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the crawl to the writer as a JUnit XML report with one
+// test case per URL. Error statuses (4xx, 5xx and other statuses that are
+// neither successful nor redirects), redirect loops and fetch errors are
+// reported as failures listing the referring pages. Pages that were never
+// fetched, for example because the crawl timed out, are reported as skipped.
+func (s *SiteMap) WriteJUnit(out io.Writer) error {
+	pages := s.Pages()
+
+	suite := junitTestSuite{
+		Name:  strings.Join(s.Roots(), ", "),
+		Tests: len(pages),
+		Time:  junitSeconds(s.metadata.Duration().Seconds()),
+		Cases: make([]junitTestCase, 0, len(pages)),
+	}
+
+	if !s.metadata.StartTime.IsZero() {
+		suite.Timestamp = s.metadata.StartTime.Format("2006-01-02T15:04:05")
+	}
+
+	redirects := map[string]string{}
+	for _, page := range pages {
+		if page.Redirect != "" {
+			redirects[page.URL] = page.Redirect
+		}
+	}
+
+	for _, page := range pages {
+		testCase := junitTestCase{
+			ClassName: junitClassName(page.URL),
+			Name:      page.URL,
+			Time:      junitSeconds(page.Duration.Seconds()),
+		}
+
+		failureType, failureMessage := pageFailure(&page, redirects)
+		switch {
+		case failureType != "":
+			testCase.Failure = junitPageFailure(
+				&page,
+				failureType,
+				failureMessage,
+			)
+			suite.Failures++
+		case page.StatusCode == 0:
+			testCase.Skipped = &junitSkipped{Message: "page was not fetched"}
+			suite.Skipped++
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{
+		Suites: []junitTestSuite{suite},
+	}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// pageFailure returns the type and message of the failure of a page, or empty
+// strings if the page did not fail.
+func pageFailure(page *Page, redirects map[string]string) (string, string) {
+	switch {
+	case page.Error != "":
+		return "error", page.Error
+	case page.Redirect != "" && isRedirectLoop(page.URL, redirects):
+		return "redirect loop", fmt.Sprintf(
+			"redirect loop from %s",
+			page.URL,
+		)
+	case page.StatusCode >= 400 ||
+		(page.StatusCode != 0 && page.StatusCode < 200):
+		return "status", fmt.Sprintf("status %d", page.StatusCode)
+	default:
+		return "", ""
+	}
+}
+
+// isRedirectLoop follows redirects from the URL and returns true if a URL in
+// the chain is visited twice.
+func isRedirectLoop(start string, redirects map[string]string) bool {
+	visited := map[string]bool{}
+	for current := start; current != ""; current = redirects[current] {
+		if visited[current] {
+			return true
+		}
+		visited[current] = true
+	}
+	return false
+}
+
+// junitPageFailure creates a failure that lists the pages referring to the
+// failed page.
+func junitPageFailure(page *Page, failureType, message string) *junitFailure {
+	var body strings.Builder
+	if len(page.Inlinks) > 0 {
+		message = fmt.Sprintf(
+			"%s (referred to by %s)",
+			message,
+			strings.Join(page.Inlinks, ", "),
+		)
+
+		body.WriteString("Referring pages:\n")
+		for _, referrer := range page.Inlinks {
+			body.WriteString(referrer)
+			body.WriteString("\n")
+		}
+	}
+
+	return &junitFailure{
+		Message: message,
+		Type:    failureType,
+		Body:    body.String(),
+	}
+}
+
+// junitClassName groups test cases by the origin of the URL.
+func junitClassName(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+func junitSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/", "status": 200},
+			{"url": "http://example.com/a", "status": 301,
+				"redirect": "http://example.com/b"},
+			{"url": "http://example.com/b", "status": 302,
+				"redirect": "http://example.com/a"},
+			{"url": "http://example.com/c", "status": 301,
+				"redirect": "http://example.com/"},
+			{"url": "http://example.com/d", "error": "http get error"},
+			{"url": "http://example.com/e", "status": 404,
+				"inlinks": ["http://example.com/", "http://example.com/c"]},
+			{"url": "http://example.com/f"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	var out bytes.Buffer
+	if err := sitemap.WriteJUnit(&out); err != nil {
+		t.Fatalf("error writing junit report: %q", err)
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("error parsing junit report: %q\n\n%s", err, out.String())
+	}
+
+	if len(report.Suites) != 1 {
+		t.Fatalf(
+			"expected a single test suite but got %d",
+			len(report.Suites),
+		)
+	}
+
+	suite := report.Suites[0]
+	if suite.Tests != 7 || suite.Failures != 4 || suite.Skipped != 1 {
+		t.Errorf(
+			"expected 7 tests, 4 failures and 1 skipped but got %d, %d, %d",
+			suite.Tests,
+			suite.Failures,
+			suite.Skipped,
+		)
+	}
+
+	expectedFailures := map[string]string{
+		"http://example.com/a": "redirect loop",
+		"http://example.com/b": "redirect loop",
+		"http://example.com/d": "error",
+		"http://example.com/e": "status",
+	}
+
+	for _, testCase := range suite.Cases {
+		expectedType := expectedFailures[testCase.Name]
+
+		if testCase.Failure == nil {
+			if expectedType != "" {
+				t.Errorf("expected %s to fail", testCase.Name)
+			}
+			continue
+		}
+
+		if testCase.Failure.Type != expectedType {
+			t.Errorf(
+				"expected failure type %q for %s but got %q",
+				expectedType,
+				testCase.Name,
+				testCase.Failure.Type,
+			)
+		}
+
+		if testCase.ClassName != "http://example.com" {
+			t.Errorf("unexpected class name %q", testCase.ClassName)
+		}
+	}
+
+	expectedMessage := `message="status 404 (referred to by ` +
+		`http://example.com/, http://example.com/c)"`
+	if !strings.Contains(out.String(), expectedMessage) {
+		t.Errorf(
+			"expected failure message to list referrers\n\n%s",
+			out.String(),
+		)
+	}
+}
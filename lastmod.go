@@ -0,0 +1,63 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+// InferLastModified sets the last modified time of pages that have no
+// Last-Modified header by comparing their content hash with a previous crawl.
+// Pages whose content is unchanged keep the last modified time of the previous
+// crawl, while pages that are new or whose content changed are considered
+// modified at the start of this crawl. Unchanged pages without a last modified
+// time in the previous crawl are considered modified at the start of the
+// previous crawl, or of this crawl if that is unknown. Pages are left unchanged
+// when the previous crawl has no content hash to compare with.
+func (s *SiteMap) InferLastModified(previous *SiteMap) {
+	previous.rwl.RLock()
+	defer previous.rwl.RUnlock()
+
+	s.rwl.Lock()
+	defer s.rwl.Unlock()
+
+	crawlTime := s.metadata.StartTime
+	previousCrawlTime := previous.metadata.StartTime
+	if previousCrawlTime.IsZero() {
+		previousCrawlTime = crawlTime
+	}
+
+	for urlString, page := range s.siteURLS {
+		if page.LastModified != nil || page.ContentHash == "" {
+			continue
+		}
+
+		previousPage := previous.siteURLS[urlString]
+		switch {
+		case previousPage == nil:
+			page.LastModified = &crawlTime
+		case previousPage.ContentHash == "":
+		case previousPage.ContentHash == page.ContentHash &&
+			previousPage.LastModified == nil:
+			page.LastModified = &previousCrawlTime
+		case previousPage.ContentHash == page.ContentHash:
+			page.LastModified = previousPage.LastModified
+		default:
+			page.LastModified = &crawlTime
+		}
+	}
+}
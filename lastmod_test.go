@@ -0,0 +1,183 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCrawlRecordsLastModified(t *testing.T) {
+	lastModified := time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)
+	body := `<a href="/dated">dated</a><a href="/plain">plain</a>`
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/dated" {
+				w.Header().Set(
+					"Last-Modified",
+					lastModified.Format(http.TimeFormat),
+				)
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(body))
+		},
+	))
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	bodyHash := sha256.Sum256([]byte(body))
+	expectedHash := hex.EncodeToString(bodyHash[:])
+
+	for _, page := range sitemap.Pages() {
+		if page.ContentHash != expectedHash {
+			t.Errorf(
+				"expected content hash %s for %s but got %s",
+				expectedHash,
+				page.URL,
+				page.ContentHash,
+			)
+		}
+
+		dated := strings.HasSuffix(page.URL, "/dated")
+		if dated && (page.LastModified == nil ||
+			!page.LastModified.Equal(lastModified)) {
+			t.Errorf("expected last modified %s for %s", lastModified, page.URL)
+		}
+		if !dated && page.LastModified != nil {
+			t.Errorf("expected no last modified time for %s", page.URL)
+		}
+	}
+}
+
+func TestInferLastModified(t *testing.T) {
+	previous, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"metadata": {"startTime": "2020-04-01T00:00:00Z"},
+		"urls": [
+			{"url": "http://example.com/same", "contentHash": "aa",
+				"lastModified": "2020-03-01T00:00:00Z"},
+			{"url": "http://example.com/same-unknown", "contentHash": "aa"},
+			{"url": "http://example.com/changed", "contentHash": "aa",
+				"lastModified": "2020-03-01T00:00:00Z"},
+			{"url": "http://example.com/unfetched"},
+			{"url": "http://example.com/header", "contentHash": "aa"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading previous snapshot: %q", err)
+	}
+
+	current, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"metadata": {"startTime": "2020-05-01T00:00:00Z"},
+		"urls": [
+			{"url": "http://example.com/same", "contentHash": "aa"},
+			{"url": "http://example.com/same-unknown", "contentHash": "aa"},
+			{"url": "http://example.com/changed", "contentHash": "bb"},
+			{"url": "http://example.com/unfetched", "contentHash": "aa"},
+			{"url": "http://example.com/header", "contentHash": "bb",
+				"lastModified": "2019-01-01T00:00:00Z"},
+			{"url": "http://example.com/new", "contentHash": "cc"},
+			{"url": "http://example.com/failed"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading current snapshot: %q", err)
+	}
+
+	current.InferLastModified(previous)
+
+	expected := map[string]string{
+		"http://example.com/same":         "2020-03-01T00:00:00Z",
+		"http://example.com/same-unknown": "2020-04-01T00:00:00Z",
+		"http://example.com/changed":      "2020-05-01T00:00:00Z",
+		"http://example.com/unfetched":    "",
+		"http://example.com/header":       "2019-01-01T00:00:00Z",
+		"http://example.com/new":          "2020-05-01T00:00:00Z",
+		"http://example.com/failed":       "",
+	}
+
+	for _, page := range current.Pages() {
+		var got string
+		if page.LastModified != nil {
+			got = page.LastModified.Format(time.RFC3339)
+		}
+		if got != expected[page.URL] {
+			t.Errorf(
+				"expected last modified %q for %s but got %q",
+				expected[page.URL],
+				page.URL,
+				got,
+			)
+		}
+	}
+}
+
+func TestInferLastModifiedWithoutPreviousStartTime(t *testing.T) {
+	previous, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/same-unknown", "contentHash": "aa"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading previous snapshot: %q", err)
+	}
+
+	current, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"metadata": {"startTime": "2020-05-01T00:00:00Z"},
+		"urls": [
+			{"url": "http://example.com/same-unknown", "contentHash": "aa"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading current snapshot: %q", err)
+	}
+
+	current.InferLastModified(previous)
+
+	expected := "2020-05-01T00:00:00Z"
+	page := current.Pages()[0]
+	if page.LastModified == nil ||
+		page.LastModified.Format(time.RFC3339) != expected {
+		t.Errorf("expected last modified %q but got %v", expected, page.LastModified)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// errSessionExpired is returned when a page redirects to the login form
+// because the login session has expired.
+var errSessionExpired = errors.New("login session expired")
+
+// LoginForm is a login form that is submitted before crawling. The fields are
+// posted to the URL and the session cookies of the response are kept in the
+// cookie jar of the client. When a page redirects to a location that matches
+// ExpiredRedirect the session is considered expired, the form is submitted
+// again and the page is fetched once more.
+type LoginForm struct {
+	URL             string
+	Fields          url.Values
+	ExpiredRedirect *regexp.Regexp
+}
+
+// login submits the login form. A response with an error status, or one that
+// redirects to the expired session location, is treated as a failed login.
+func (crawler *DomainCrawler) login() error {
+	form := crawler.config.LoginForm
+
+	loginURL, err := url.Parse(form.URL)
+	if err != nil {
+		return fmt.Errorf("login error: %q", err)
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		loginURL.String(),
+		strings.NewReader(form.Fields.Encode()),
+	)
+	if err != nil {
+		return fmt.Errorf("login error: %q", err)
+	}
+	for name, values := range crawler.pageRequestHeader(loginURL) {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := crawler.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("login error: %q", err)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login failed with status %d", resp.StatusCode)
+	}
+
+	if location, err := resp.Location(); err == nil &&
+		form.ExpiredRedirect != nil &&
+		form.ExpiredRedirect.MatchString(location.String()) {
+		return fmt.Errorf("login failed with redirect to %s", location)
+	}
+
+	return nil
+}
+
+// loginCount returns the number of times the login form has been submitted.
+func (crawler *DomainCrawler) loginCount() int {
+	crawler.loginLock.Lock()
+	defer crawler.loginLock.Unlock()
+
+	return crawler.logins
+}
+
+// relogin submits the login form unless another goroutine has logged in since
+// the given login count was read, so that pages that find the session expired
+// at the same time only trigger a single login.
+func (crawler *DomainCrawler) relogin(count int) error {
+	crawler.loginLock.Lock()
+	defer crawler.loginLock.Unlock()
+
+	if crawler.logins != count {
+		return nil
+	}
+
+	crawler.config.Logger.Info("logging in",
+		zap.String("url", crawler.config.LoginForm.URL),
+	)
+
+	if err := crawler.login(); err != nil {
+		return err
+	}
+
+	crawler.logins++
+	return nil
+}
+
+// isSessionExpired returns true if the page redirected to a location that
+// matches the expired session pattern of the login form.
+func (crawler *DomainCrawler) isSessionExpired(linkReader *LinkReader) bool {
+	form := crawler.config.LoginForm
+	if form == nil || form.ExpiredRedirect == nil {
+		return false
+	}
+
+	redirect := linkReader.Redirect()
+	return redirect != "" && form.ExpiredRedirect.MatchString(redirect)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	testServer "github.com/Matt-Esch/sitemapper/test/server"
+	"go.uber.org/zap"
+)
+
+func TestLoginForm(t *testing.T) {
+	loginHandler := testServer.NewLoginHandler(
+		cachedDirectoryHandler(),
+		"/login",
+		"user",
+		"secret",
+		3,
+	)
+	server := httptest.NewServer(loginHandler)
+	defer server.Close()
+
+	sitemap, err := CrawlDomain(
+		server.URL,
+		SetClient(server.Client()),
+		SetLogger(zap.NewNop()),
+		SetMaxConcurrency(1),
+		SetLoginForm(&LoginForm{
+			URL: server.URL + "/login",
+			Fields: url.Values{
+				"username": {"user"},
+				"password": {"secret"},
+			},
+			ExpiredRedirect: regexp.MustCompile(`/login$`),
+		}),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	for _, page := range sitemap.Pages() {
+		if page.Redirect != "" || page.Error != "" {
+			t.Errorf(
+				"expected %s to be crawled while logged in but got %v",
+				page.URL,
+				page,
+			)
+		}
+	}
+
+	about := server.URL + "/about"
+	found := false
+	for _, pageURL := range sitemap.URLs() {
+		found = found || pageURL == about
+	}
+	if !found {
+		t.Errorf("expected %s to be crawled", about)
+	}
+
+	// Sessions expire after 3 requests, so the crawl must log in again
+	if loginHandler.Logins() < 2 {
+		t.Errorf("expected the crawler to log in again after expiry")
+	}
+}
+
+func TestLoginFormFailed(t *testing.T) {
+	server := httptest.NewServer(testServer.NewLoginHandler(
+		cachedDirectoryHandler(),
+		"/login",
+		"user",
+		"secret",
+		0,
+	))
+	defer server.Close()
+
+	_, err := CrawlDomain(
+		server.URL,
+		SetClient(server.Client()),
+		SetLogger(zap.NewNop()),
+		SetLoginForm(&LoginForm{
+			URL:    server.URL + "/login",
+			Fields: url.Values{"username": {"user"}, "password": {"wrong"}},
+		}),
+	)
+
+	expectedErr := "login failed with status 401"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q but got %q", expectedErr, err)
+	}
+}
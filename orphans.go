@@ -0,0 +1,257 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxReferenceSitemaps limits the number of nested sitemaps that are loaded
+// when a reference sitemap is a sitemap index.
+const maxReferenceSitemaps = 1000
+
+// sitemapDocument matches both the urlset and sitemapindex sitemap formats.
+// Only the loc elements are of interest when comparing against a crawl.
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// ReadReferenceSitemap parses a sitemap XML document and returns the URLs it
+// lists. Nested sitemaps of a sitemap index are returned separately so that
+// the caller can decide how they should be loaded.
+func ReadReferenceSitemap(in io.Reader) (urls []string, sitemaps []string, err error) {
+	var doc sitemapDocument
+	if err := xml.NewDecoder(in).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid sitemap: %s", err)
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return nil, nil, fmt.Errorf(
+			"invalid sitemap: unexpected root element %q",
+			doc.XMLName.Local,
+		)
+	}
+
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			urls = append(urls, loc)
+		}
+	}
+
+	for _, s := range doc.Sitemaps {
+		if loc := strings.TrimSpace(s.Loc); loc != "" {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+
+	return urls, sitemaps, nil
+}
+
+// LoadReferenceSitemap loads the URLs listed in the sitemap at the given
+// location, which is either a local file path or an http(s) URL. Sitemap
+// indexes are followed and the nested sitemaps are fetched with the client.
+func LoadReferenceSitemap(location string, client *http.Client) ([]string, error) {
+	var urls []string
+
+	pending := []string{location}
+	seen := map[string]bool{location: true}
+
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+
+		docURLS, docSitemaps, err := loadSitemapDocument(next, client)
+		if err != nil {
+			return nil, err
+		}
+
+		urls = append(urls, docURLS...)
+
+		for _, sitemap := range docSitemaps {
+			if seen[sitemap] {
+				continue
+			}
+			if len(seen) >= maxReferenceSitemaps {
+				return nil, fmt.Errorf(
+					"sitemap index %s lists more than %d sitemaps",
+					location,
+					maxReferenceSitemaps,
+				)
+			}
+			seen[sitemap] = true
+			pending = append(pending, sitemap)
+		}
+	}
+
+	return urls, nil
+}
+
+// loadSitemapDocument reads a single sitemap document from a file or URL.
+func loadSitemapDocument(location string, client *http.Client) ([]string, []string, error) {
+	if !strings.HasPrefix(location, "http://") &&
+		!strings.HasPrefix(location, "https://") {
+		file, err := os.Open(location)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+
+		return ReadReferenceSitemap(file)
+	}
+
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, nil, fmt.Errorf("http get error: %q", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf(
+			"unable to load sitemap %s: status %d",
+			location,
+			resp.StatusCode,
+		)
+	}
+
+	return ReadReferenceSitemap(resp.Body)
+}
+
+// OrphanReport is the result of comparing a crawled site map against a
+// reference sitemap. Orphans are listed in the reference sitemap but were not
+// reachable by following links. Unlisted pages were found by the crawl but are
+// missing from the reference sitemap.
+type OrphanReport struct {
+	Orphans  []string `json:"orphans"`
+	Unlisted []string `json:"unlisted"`
+	Both     []string `json:"both"`
+}
+
+// CompareReference compares the crawled URLs with the URLs of a reference
+// sitemap. URLs are normalized before comparison so that equivalent encodings
+// of the same URL match. Only the pages that would be written to a sitemap are
+// compared, so that redirects, broken pages and pages that ask not to be
+// indexed are not reported as missing from the reference sitemap.
+func (s *SiteMap) CompareReference(reference []string) *OrphanReport {
+	report := &OrphanReport{
+		Orphans:  []string{},
+		Unlisted: []string{},
+		Both:     []string{},
+	}
+
+	referenced := map[string]bool{}
+	for _, ref := range reference {
+		referenced[normalizeURLString(ref)] = true
+	}
+
+	crawled := map[string]bool{}
+	for _, page := range s.Pages() {
+		if !isSitemapPage(&page) || s.isExcludedNoIndex(&page) {
+			continue
+		}
+
+		normalized := normalizeURLString(page.URL)
+		crawled[normalized] = true
+
+		if referenced[normalized] {
+			report.Both = append(report.Both, normalized)
+		} else {
+			report.Unlisted = append(report.Unlisted, normalized)
+		}
+	}
+
+	for ref := range referenced {
+		if !crawled[ref] {
+			report.Orphans = append(report.Orphans, ref)
+		}
+	}
+
+	sort.Strings(report.Orphans)
+	sort.Strings(report.Unlisted)
+	sort.Strings(report.Both)
+
+	return report
+}
+
+// WriteText writes a human readable version of the report to the writer.
+func (r *OrphanReport) WriteText(out io.Writer) error {
+	sections := []struct {
+		title string
+		urls  []string
+	}{
+		{"Orphans (in sitemap, not linked)", r.Orphans},
+		{"Unlisted (linked, not in sitemap)", r.Unlisted},
+		{"In both", r.Both},
+	}
+
+	for i, section := range sections {
+		if i > 0 {
+			if _, err := io.WriteString(out, "\n"); err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(out, "%s: %d\n", section.title, len(section.urls))
+		if err != nil {
+			return err
+		}
+
+		for _, u := range section.urls {
+			if _, err := fmt.Fprintf(out, "  %s\n", u); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as a JSON object to the writer.
+func (r *OrphanReport) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// normalizeURLString returns the canonical string encoding of a URL. Strings
+// which can't be parsed are returned unmodified.
+func normalizeURLString(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return parsed.String()
+}
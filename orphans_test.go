@@ -0,0 +1,197 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCompareReference(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error reading example site map: %q", err)
+	}
+
+	reference := strings.NewReader(fmt.Sprintf(
+		`<?xml version="1.0" encoding="UTF-8"?>
+		<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+			<url><loc>%[1]s/</loc></url>
+			<url><loc> %[1]s/about </loc></url>
+			<url><loc>%[1]s/images</loc></url>
+			<url><loc>%[1]s/orphan</loc></url>
+		</urlset>`,
+		testServer.URL,
+	))
+
+	referenceURLS, _, err := ReadReferenceSitemap(reference)
+	if err != nil {
+		t.Fatalf("error reading reference sitemap: %q", err)
+	}
+
+	report := sitemap.CompareReference(referenceURLS)
+
+	expected := &OrphanReport{
+		Orphans: prefixURLS(testServer.URL, "/orphan"),
+		Unlisted: prefixURLS(
+			testServer.URL,
+			"",
+			"/fr/about",
+			"/hidden",
+			"/hidden?t=0",
+			"/rectangle",
+			"/square",
+		),
+		Both: prefixURLS(testServer.URL, "/", "/about", "/images"),
+	}
+
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf(
+			"unexpected orphan report.\n\nGot:\n\n%v\n\nExpected:\n\n%v",
+			report,
+			expected,
+		)
+	}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatalf("error writing report: %q", err)
+	}
+	if !strings.Contains(text.String(), "Orphans (in sitemap, not linked): 1") {
+		t.Errorf("unexpected text report:\n\n%s", text.String())
+	}
+}
+
+func TestCompareReferenceSkipsExcludedPages(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			switch r.URL.Path {
+			case "/":
+				io.WriteString(w, `<a href="/page">page</a>`+
+					`<a href="/noindex">noindex</a>`+
+					`<a href="/missing">missing</a>`)
+			case "/noindex":
+				io.WriteString(w, `<meta name="robots" content="noindex">`)
+			case "/missing":
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+	))
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL+"/",
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	report := sitemap.CompareReference(prefixURLS(testServer.URL, "/"))
+
+	expected := &OrphanReport{
+		Orphans:  []string{},
+		Unlisted: prefixURLS(testServer.URL, "/page"),
+		Both:     prefixURLS(testServer.URL, "/"),
+	}
+
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf(
+			"unexpected orphan report.\n\nGot:\n\n%v\n\nExpected:\n\n%v",
+			report,
+			expected,
+		)
+	}
+}
+
+func TestLoadReferenceSitemapIndex(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w,
+			`<sitemapindex>
+				<sitemap><loc>%[1]s/a.xml</loc></sitemap>
+				<sitemap><loc>%[1]s/b.xml</loc></sitemap>
+				<sitemap><loc>%[1]s/sitemap.xml</loc></sitemap>
+			</sitemapindex>`,
+			server.URL,
+		)
+	})
+	mux.HandleFunc("/a.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset><url><loc>http://a.com/</loc></url></urlset>`)
+	})
+	mux.HandleFunc("/b.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset><url><loc>http://b.com/</loc></url></urlset>`)
+	})
+
+	urls, err := LoadReferenceSitemap(server.URL+"/sitemap.xml", server.Client())
+	if err != nil {
+		t.Fatalf("error loading reference sitemap: %q", err)
+	}
+
+	expected := []string{"http://a.com/", "http://b.com/"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("expected urls %v but got %v", expected, urls)
+	}
+
+	_, err = LoadReferenceSitemap(server.URL+"/missing.xml", server.Client())
+	if err == nil {
+		t.Errorf("expected error loading missing sitemap")
+	}
+}
+
+func TestReadReferenceSitemapInvalid(t *testing.T) {
+	expectedErr := `invalid sitemap: unexpected root element "html"`
+
+	_, _, err := ReadReferenceSitemap(strings.NewReader("<html></html>"))
+
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected invalid sitemap error but got %q", err)
+	}
+}
+
+// prefixURLS prefixes each of the paths with the root URL.
+func prefixURLS(root string, paths ...string) []string {
+	urls := make([]string, len(paths))
+	for i, path := range paths {
+		urls[i] = root + path
+	}
+	return urls
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import "time"
+
+// Page holds the crawl result for a single URL in the site map. A status code
+// of 0 means that no response was received, either because the request failed
+// or because the page was never fetched. Depth is the fewest links followed
+// from a root to reach the page and the referrer is a page on such a shortest
+// path. Inlinks lists every page in the crawl that links to the page.
+// Size is the number of bytes in the response body and Duration is the time
+// taken to fetch and read the page. Redirect is the location of a redirect
+// response. Images lists the images found on the page and Alternates lists
+// the localized versions of the page. NoIndex and NoFollow are the robots
+// directives of the page. LastModified is taken from the Last-Modified header
+// or inferred from changes to the ContentHash of the body between crawls.
+type Page struct {
+	URL          string        `json:"url"`
+	StatusCode   int           `json:"status,omitempty"`
+	Depth        int           `json:"depth"`
+	Referrer     string        `json:"referrer,omitempty"`
+	Inlinks      []string      `json:"inlinks,omitempty"`
+	ContentType  string        `json:"contentType,omitempty"`
+	Size         int64         `json:"size,omitempty"`
+	Duration     time.Duration `json:"duration,omitempty"`
+	Redirect     string        `json:"redirect,omitempty"`
+	Title        string        `json:"title,omitempty"`
+	Canonical    string        `json:"canonical,omitempty"`
+	Images       []string      `json:"images,omitempty"`
+	Alternates   []Alternate   `json:"alternates,omitempty"`
+	NoIndex      bool          `json:"noindex,omitempty"`
+	NoFollow     bool          `json:"nofollow,omitempty"`
+	LastModified *time.Time    `json:"lastModified,omitempty"`
+	ContentHash  string        `json:"contentHash,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// Alternate is a localized version of a page, declared with a link tag such as
+// <link rel="alternate" hreflang="fr" href="...">.
+type Alternate struct {
+	Hreflang string `json:"hreflang"`
+	URL      string `json:"url"`
+}
+
+// A PageHandler is notified of each page as soon as it has been fetched. The
+// handler is called concurrently from the crawling goroutines, so it must be
+// safe for concurrent use. The depth and referrer of a handled page are the
+// ones known when it was fetched. They are provisional, as a shorter path to
+// the page may be found later, and may differ from the final site map.
+type PageHandler interface {
+	HandlePage(page Page)
+}
+
+// PageHandlerFunc acts as an adapter for allowing the use of ordinary
+// functions as page handlers.
+type PageHandlerFunc func(page Page)
+
+// HandlePage calls f(page).
+func (f PageHandlerFunc) HandlePage(page Page) {
+	f(page)
+}
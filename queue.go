@@ -0,0 +1,342 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// urlQueue holds the URLs waiting to be crawled. URLs are queued per origin
+// and handed out round-robin across origins, so that a site with many links
+// can't starve the others. At most maxPerHost URLs of an origin are crawled at
+// once. When a circuit breaker is configured, origins whose breaker is open
+// are skipped until their cooldown has passed, or dropped once the queue has
+// expired. The queue closes once every URL that was pushed has been marked
+// done.
+type urlQueue struct {
+	lock sync.Mutex
+
+	// cond wakes the goroutines waiting in pop for a URL and closedCond
+	// wakes the goroutines waiting in wait for the queue to close.
+	cond       *sync.Cond
+	closedCond *sync.Cond
+
+	hosts      map[string]*hostQueue
+	ready      []*hostQueue
+	next       int
+	size       int
+	maxSize    int
+	maxPerHost int
+	remaining  int
+	closed     bool
+	stopped    bool
+	expired    bool
+
+	// newBreaker creates the circuit breaker of an origin, and is nil when
+	// circuit breakers are disabled.
+	newBreaker func(origin string) *circuitBreaker
+	breakers   map[string]*circuitBreaker
+}
+
+// hostQueue is the queue of pending URLs of a single origin.
+type hostQueue struct {
+	key    string
+	urls   []*url.URL
+	active int
+}
+
+func newURLQueue(maxSize int, maxPerHost int) *urlQueue {
+	queue := &urlQueue{
+		hosts:      map[string]*hostQueue{},
+		breakers:   map[string]*circuitBreaker{},
+		maxSize:    maxSize,
+		maxPerHost: maxPerHost,
+	}
+	queue.cond = sync.NewCond(&queue.lock)
+	queue.closedCond = sync.NewCond(&queue.lock)
+
+	return queue
+}
+
+// push adds the URL to the queue of its origin. False is returned if the
+// queue is full, closed or stopped, in which case the URL is dropped.
+func (q *urlQueue) push(pageURL *url.URL) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed || q.stopped || q.size >= q.maxSize {
+		return false
+	}
+
+	key := hostKey(pageURL)
+	host, ok := q.hosts[key]
+	if !ok {
+		host = &hostQueue{key: key}
+		q.hosts[key] = host
+	}
+
+	if len(host.urls) == 0 {
+		q.ready = append(q.ready, host)
+	}
+	host.urls = append(host.urls, pageURL)
+
+	q.size++
+	q.remaining++
+	q.cond.Signal()
+
+	return true
+}
+
+// pop blocks until a URL can be crawled without exceeding the per host limit
+// and returns it. False is returned once the queue is closed.
+func (q *urlQueue) pop() (*url.URL, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for !q.closed {
+		if pageURL := q.take(); pageURL != nil {
+			return pageURL, true
+		}
+		q.cond.Wait()
+	}
+
+	return nil, false
+}
+
+// take removes the next URL in round-robin order from an origin that is below
+// the per host limit and not paused by its circuit breaker. The lock must be
+// held.
+func (q *urlQueue) take() *url.URL {
+	now := time.Now()
+	for i := 0; i < len(q.ready); i++ {
+		index := (q.next + i) % len(q.ready)
+		host := q.ready[index]
+		if host.active >= q.maxPerHost {
+			continue
+		}
+
+		if breaker := q.breakers[host.key]; breaker != nil &&
+			!breaker.allow(now, host.urls[0].String()) {
+			continue
+		}
+
+		pageURL := host.urls[0]
+		host.urls[0] = nil
+		host.urls = host.urls[1:]
+		host.active++
+		q.size--
+
+		// Hosts with nothing left to crawl leave the rotation, which moves
+		// the following host into the current index.
+		if len(host.urls) == 0 {
+			q.ready = append(q.ready[:index], q.ready[index+1:]...)
+			q.next = index
+		} else {
+			q.next = index + 1
+		}
+
+		if len(q.ready) > 0 {
+			q.next %= len(q.ready)
+		} else {
+			q.next = 0
+		}
+
+		return pageURL
+	}
+
+	return nil
+}
+
+// done marks a URL returned by pop as crawled. If the URL was the probe of a
+// circuit breaker and its outcome was never reported, the probe is released.
+// The queue is closed when no URLs are left to crawl.
+func (q *urlQueue) done(pageURL *url.URL) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	key := hostKey(pageURL)
+	if breaker := q.breakers[key]; breaker != nil &&
+		breaker.release(pageURL.String()) {
+		q.cond.Broadcast()
+	}
+
+	if host, ok := q.hosts[key]; ok {
+		host.active--
+		if host.active == 0 && len(host.urls) == 0 {
+			delete(q.hosts, key)
+		}
+	}
+
+	q.remaining--
+	if q.remaining == 0 {
+		q.close()
+		return
+	}
+
+	q.cond.Signal()
+}
+
+// report records whether the request for a URL returned by pop failed with
+// the circuit breaker of its origin. While the breaker is open, waiting
+// goroutines are woken up when the cooldown has passed. An error is returned
+// when the origin has been failing for longer than the breaker deadline.
+func (q *urlQueue) report(pageURL *url.URL, failed bool) (breakerEvent, error) {
+	if q.newBreaker == nil {
+		return breakerUnchanged, nil
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	key := hostKey(pageURL)
+	breaker := q.breakers[key]
+	if breaker == nil {
+		if !failed {
+			return breakerUnchanged, nil
+		}
+		breaker = q.newBreaker(key)
+		q.breakers[key] = breaker
+	}
+
+	now := time.Now()
+	event, err := breaker.record(now, pageURL.String(), failed)
+	switch event {
+	case breakerOpened:
+		if q.expired {
+			q.dropHost(key)
+			break
+		}
+		time.AfterFunc(breaker.openUntil.Sub(now), q.wake)
+	case breakerClosed:
+		q.cond.Broadcast()
+	}
+
+	return event, err
+}
+
+// wake wakes up the goroutines waiting for a URL.
+func (q *urlQueue) wake() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.cond.Broadcast()
+}
+
+// stop drops the queued URLs and refuses new ones. The queue closes once the
+// URLs being crawled are done.
+func (q *urlQueue) stop() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.stopped = true
+	q.remaining -= q.size
+	q.size = 0
+	q.ready = nil
+	q.next = 0
+	for key, host := range q.hosts {
+		host.urls = nil
+		if host.active == 0 {
+			delete(q.hosts, key)
+		}
+	}
+
+	if q.remaining == 0 {
+		q.close()
+		return
+	}
+	q.cond.Broadcast()
+}
+
+// close closes the queue and wakes up every waiting goroutine. The lock must
+// be held.
+func (q *urlQueue) close() {
+	q.closed = true
+	q.cond.Broadcast()
+	q.closedCond.Broadcast()
+}
+
+// expire drops the queued URLs of origins whose circuit breaker is open, and
+// of origins whose breaker opens from now on, rather than waiting out their
+// cooldown. It is used once the crawl has timed out and queued URLs are no
+// longer fetched.
+func (q *urlQueue) expire() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.expired = true
+	for key, breaker := range q.breakers {
+		if breaker.isOpen() {
+			q.dropHost(key)
+		}
+	}
+}
+
+// dropHost drops the queued URLs of the origin. The lock must be held.
+func (q *urlQueue) dropHost(key string) {
+	host, ok := q.hosts[key]
+	if !ok || len(host.urls) == 0 {
+		return
+	}
+
+	q.size -= len(host.urls)
+	q.remaining -= len(host.urls)
+	host.urls = nil
+	if host.active == 0 {
+		delete(q.hosts, key)
+	}
+
+	for index, readyHost := range q.ready {
+		if readyHost == host {
+			q.ready = append(q.ready[:index], q.ready[index+1:]...)
+			if q.next > index {
+				q.next--
+			}
+			break
+		}
+	}
+	if len(q.ready) > 0 {
+		q.next %= len(q.ready)
+	} else {
+		q.next = 0
+	}
+
+	if q.remaining == 0 {
+		q.close()
+	}
+}
+
+// wait blocks until the queue is closed.
+func (q *urlQueue) wait() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for !q.closed {
+		q.closedCond.Wait()
+	}
+}
+
+// hostKey returns the origin that the URL is queued under.
+func hostKey(pageURL *url.URL) string {
+	return strings.ToLower(pageURL.Scheme + "://" + pageURL.Host)
+}
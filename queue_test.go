@@ -0,0 +1,292 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestURLQueueRoundRobin(t *testing.T) {
+	queue := newURLQueue(10, 10)
+	for _, link := range []string{
+		"http://a.com/1",
+		"http://a.com/2",
+		"http://a.com/3",
+		"http://b.com/1",
+		"http://c.com/1",
+		"http://c.com/2",
+	} {
+		if !queue.push(mustParseURL(t, link)) {
+			t.Fatalf("expected %s to be queued", link)
+		}
+	}
+
+	expected := []string{
+		"http://a.com/1",
+		"http://b.com/1",
+		"http://c.com/1",
+		"http://a.com/2",
+		"http://c.com/2",
+		"http://a.com/3",
+	}
+
+	var popped []string
+	for range expected {
+		pageURL, ok := queue.pop()
+		if !ok {
+			t.Fatalf("expected queue to be open")
+		}
+		popped = append(popped, pageURL.String())
+	}
+
+	if !reflect.DeepEqual(popped, expected) {
+		t.Errorf("expected urls in order %v but got %v", expected, popped)
+	}
+}
+
+func TestURLQueueHostLimit(t *testing.T) {
+	queue := newURLQueue(10, 1)
+	first := mustParseURL(t, "http://a.com/1")
+	queue.push(first)
+	queue.push(mustParseURL(t, "http://a.com/2"))
+	queue.push(mustParseURL(t, "http://b.com/1"))
+
+	for _, expected := range []string{"http://a.com/1", "http://b.com/1"} {
+		if pageURL, _ := queue.pop(); pageURL.String() != expected {
+			t.Fatalf("expected %s but got %s", expected, pageURL)
+		}
+	}
+
+	popped := make(chan *url.URL)
+	go func() {
+		pageURL, _ := queue.pop()
+		popped <- pageURL
+	}()
+
+	select {
+	case pageURL := <-popped:
+		t.Fatalf("expected a.com to be at its limit but got %s", pageURL)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	queue.done(first)
+
+	select {
+	case pageURL := <-popped:
+		if pageURL.String() != "http://a.com/2" {
+			t.Errorf("expected http://a.com/2 but got %s", pageURL)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected a.com to be crawled after the first page was done")
+	}
+}
+
+func TestURLQueueFull(t *testing.T) {
+	queue := newURLQueue(2, 1)
+
+	queue.push(mustParseURL(t, "http://a.com/1"))
+	queue.push(mustParseURL(t, "http://b.com/1"))
+
+	if queue.push(mustParseURL(t, "http://c.com/1")) {
+		t.Errorf("expected url to be dropped when the queue is full")
+	}
+}
+
+func TestURLQueueClosesWhenDone(t *testing.T) {
+	queue := newURLQueue(2, 1)
+	queue.push(mustParseURL(t, "http://a.com/1"))
+
+	pageURL, _ := queue.pop()
+	queue.done(pageURL)
+	queue.wait()
+
+	if pageURL, ok := queue.pop(); ok {
+		t.Errorf("expected queue to be closed but got %s", pageURL)
+	}
+
+	if queue.push(mustParseURL(t, "http://a.com/2")) {
+		t.Errorf("expected push to a closed queue to be dropped")
+	}
+}
+
+func TestURLQueuePushWakesPop(t *testing.T) {
+	queue := newURLQueue(2, 2)
+	queue.push(mustParseURL(t, "http://a.com/1"))
+	first, _ := queue.pop()
+
+	waited := make(chan bool)
+	go func() {
+		queue.wait()
+		close(waited)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	popped := make(chan *url.URL)
+	go func() {
+		pageURL, _ := queue.pop()
+		popped <- pageURL
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// The push must wake the goroutine waiting in pop rather than the one
+	// waiting for the queue to close
+	queue.push(mustParseURL(t, "http://a.com/2"))
+
+	select {
+	case pageURL := <-popped:
+		queue.done(pageURL)
+	case <-time.After(time.Second):
+		t.Fatalf("expected the push to wake the goroutine waiting in pop")
+	}
+
+	queue.done(first)
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatalf("expected wait to return once the queue closed")
+	}
+}
+
+func TestCrawlHostConcurrency(t *testing.T) {
+	var lock sync.Mutex
+	active := map[string]int{}
+	maxActive := map[string]int{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		active[r.Host]++
+		if active[r.Host] > maxActive[r.Host] {
+			maxActive[r.Host] = active[r.Host]
+		}
+		lock.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		lock.Lock()
+		active[r.Host]--
+		lock.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(w, `<a href="/page-%d">page</a>`, i)
+			}
+		} else {
+			io.WriteString(w, "page")
+		}
+	})
+
+	first := httptest.NewServer(handler)
+	defer first.Close()
+
+	second := httptest.NewServer(handler)
+	defer second.Close()
+
+	sitemap, err := CrawlDomains(
+		[]string{first.URL, second.URL},
+		SetMaxConcurrency(4),
+		SetMaxHostConcurrency(1),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	if count := len(sitemap.URLs()); count != 22 {
+		t.Errorf("expected 22 urls but got %d", count)
+	}
+
+	for host, count := range maxActive {
+		if count != 1 {
+			t.Errorf("expected at most 1 request to %s at once but got %d", host, count)
+		}
+	}
+}
+
+func TestCrawlDefaultHostConcurrency(t *testing.T) {
+	var lock sync.Mutex
+	active, maxActive := 0, 0
+
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			lock.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			lock.Lock()
+			active--
+			lock.Unlock()
+
+			w.Header().Set("Content-Type", "text/html")
+			if r.URL.Path == "/" {
+				for i := 0; i < 20; i++ {
+					fmt.Fprintf(w, `<a href="/page-%d">page</a>`, i)
+				}
+			}
+		},
+	))
+	defer testServer.Close()
+
+	// The client of the test server doesn't limit connections per host, so
+	// only the queue caps the requests to the host
+	_, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	if maxActive > DefaultMaxHostConcurrency ||
+		maxActive >= DefaultMaxConcurrency {
+		t.Errorf(
+			"expected at most %d of %d requests to the host at once but got %d",
+			DefaultMaxHostConcurrency,
+			DefaultMaxConcurrency,
+			maxActive,
+		)
+	}
+}
+
+func mustParseURL(t *testing.T, link string) *url.URL {
+	pageURL, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("error parsing url %q: %q", link, err)
+	}
+
+	return pageURL
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+)
+
+// reportTopPages is the number of pages listed in the slowest and largest
+// page tables of the HTML report.
+const reportTopPages = 10
+
+// htmlReport is the data rendered by the HTML report template.
+type htmlReport struct {
+	Roots     []string
+	Metadata  CrawlMetadata
+	Pages     int
+	Fetched   int
+	TotalSize int64
+	Statuses  []statusCount
+	Slowest   []Page
+	Largest   []Page
+	Broken    []Page
+	Tree      []*siteTreeNode
+}
+
+// statusCount is a row of the status breakdown table.
+type statusCount struct {
+	Status string
+	Count  int
+}
+
+// WriteHTMLReport writes a self-contained HTML report of the crawl to the
+// writer. The report contains a summary of the crawl, a breakdown of status
+// codes, the slowest and largest pages, broken links with the pages that
+// refer to them and a browsable tree of the site's path hierarchy. The
+// report has no external dependencies and can be viewed without a server.
+func (s *SiteMap) WriteHTMLReport(out io.Writer) error {
+	pages := s.Pages()
+
+	report := htmlReport{
+		Roots:    s.Roots(),
+		Metadata: s.metadata,
+		Pages:    len(pages),
+		Tree:     buildSiteTree(pages),
+	}
+
+	statuses := map[int]int{}
+	for _, page := range pages {
+		statuses[page.StatusCode]++
+		report.TotalSize += page.Size
+
+		if page.StatusCode != 0 {
+			report.Fetched++
+		}
+
+		if page.StatusCode >= 400 || page.Error != "" {
+			report.Broken = append(report.Broken, page)
+		}
+	}
+
+	for status, count := range statuses {
+		report.Statuses = append(report.Statuses, statusCount{
+			Status: statusString(status),
+			Count:  count,
+		})
+	}
+	sort.Slice(report.Statuses, func(i, j int) bool {
+		return report.Statuses[i].Status < report.Statuses[j].Status
+	})
+
+	report.Slowest = topPages(pages, func(a, b *Page) bool {
+		return a.Duration > b.Duration
+	})
+	report.Largest = topPages(pages, func(a, b *Page) bool {
+		return a.Size > b.Size
+	})
+
+	return htmlReportTemplate.Execute(out, report)
+}
+
+// topPages returns the first pages in the given order, skipping pages which
+// were not fetched.
+func topPages(pages []Page, less func(a, b *Page) bool) []Page {
+	fetched := make([]Page, 0, len(pages))
+	for _, page := range pages {
+		if page.StatusCode != 0 {
+			fetched = append(fetched, page)
+		}
+	}
+
+	sort.SliceStable(fetched, func(i, j int) bool {
+		return less(&fetched[i], &fetched[j])
+	})
+
+	if len(fetched) > reportTopPages {
+		fetched = fetched[:reportTopPages]
+	}
+
+	return fetched
+}
+
+// formatSize formats a number of bytes for display.
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// formatDuration formats a duration in milliseconds for display.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", float64(d)/float64(time.Millisecond))
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(
+	template.FuncMap{
+		"size":     formatSize,
+		"duration": formatDuration,
+		"status":   statusString,
+	},
+).Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Crawl report for {{range $i, $root := .Roots}}{{if $i}}, {{end}}{{$root}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ccc; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.25em 1em 0.25em 0; vertical-align: top; }
+.cards { display: flex; flex-wrap: wrap; gap: 1em; }
+.card { border: 1px solid #ccc; border-radius: 4px; padding: 0.5em 1em; }
+.card .value { font-size: 1.5em; font-weight: bold; }
+.error { color: #b00; }
+.tree ul { list-style: none; padding-left: 1.5em; margin: 0; }
+.tree summary { cursor: pointer; }
+.count { color: #777; }
+</style>
+</head>
+<body>
+<h1>Crawl report for {{range $i, $root := .Roots}}{{if $i}}, {{end}}<a href="{{$root}}">{{$root}}</a>{{end}}</h1>
+{{if not .Metadata.StartTime.IsZero}}<p>Crawled {{.Metadata.StartTime.Format "2006-01-02 15:04:05 MST"}} in {{.Metadata.Duration}}{{if .Metadata.TimedOut}} (timed out, results are partial){{end}}</p>{{end}}
+
+<div class="cards">
+<div class="card"><div class="value">{{.Pages}}</div>pages</div>
+<div class="card"><div class="value">{{.Fetched}}</div>fetched</div>
+<div class="card"><div class="value">{{len .Broken}}</div>broken</div>
+<div class="card"><div class="value">{{size .TotalSize}}</div>downloaded</div>
+</div>
+
+<h2>Status codes</h2>
+<table>
+<tr><th>Status</th><th>Pages</th></tr>
+{{range .Statuses}}<tr><td>{{.Status}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+
+<h2>Broken links</h2>
+{{if .Broken}}<table>
+<tr><th>URL</th><th>Status</th><th>Referring pages</th></tr>
+{{range .Broken}}<tr>
+<td><a href="{{.URL}}">{{.URL}}</a></td>
+<td class="error">{{status .StatusCode}}{{if .Error}} {{.Error}}{{end}}</td>
+<td>{{range .Inlinks}}<a href="{{.}}">{{.}}</a><br>{{else}}-{{end}}</td>
+</tr>
+{{end}}</table>{{else}}<p>No broken links found.</p>{{end}}
+
+<h2>Slowest pages</h2>
+<table>
+<tr><th>URL</th><th>Response time</th></tr>
+{{range .Slowest}}<tr><td><a href="{{.URL}}">{{.URL}}</a></td><td>{{duration .Duration}}</td></tr>
+{{end}}</table>
+
+<h2>Largest pages</h2>
+<table>
+<tr><th>URL</th><th>Size</th></tr>
+{{range .Largest}}<tr><td><a href="{{.URL}}">{{.URL}}</a></td><td>{{size .Size}}</td></tr>
+{{end}}</table>
+
+<h2>Site structure</h2>
+<div class="tree"><ul>
+{{range .Tree}}{{template "node" .}}{{end}}</ul></div>
+</body>
+</html>
+{{define "node"}}<li>{{if .Children}}<details open><summary>{{template "label" .}}</summary>
+<ul>
+{{range .Children}}{{template "node" .}}{{end}}</ul>
+</details>{{else}}{{template "label" .}}{{end}}</li>
+{{end}}
+{{define "label"}}{{.Name}} <span class="count">({{.Count}})</span>{{range .Pages}} <a href="{{.URL}}" title="{{.URL}}">{{status .StatusCode}}</a>{{end}}{{end}}
+`))
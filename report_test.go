@@ -0,0 +1,76 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/", "status": 200, "size": 2048,
+				"duration": 5000000},
+			{"url": "http://example.com/docs/a", "status": 200, "size": 10,
+				"duration": 90000000},
+			{"url": "http://example.com/missing", "status": 404,
+				"inlinks": ["http://example.com/docs/a"]},
+			{"url": "http://example.com/<script>", "error": "http get error"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	var out bytes.Buffer
+	if err := sitemap.WriteHTMLReport(&out); err != nil {
+		t.Fatalf("error writing report: %q", err)
+	}
+
+	report := out.String()
+
+	expectedFragments := []string{
+		"<title>Crawl report for http://example.com</title>",
+		`<div class="value">4</div>pages`,
+		`<div class="value">2</div>broken`,
+		"<tr><td>404</td><td>1</td></tr>",
+		`<td class="error">404</td>`,
+		`<a href="http://example.com/docs/a">http://example.com/docs/a</a><br>`,
+		"<td>90.0 ms</td>",
+		"<td>2.0 KiB</td>",
+		"docs <span class=\"count\">(1)</span>",
+		`title="http://example.com/&lt;script&gt;"`,
+	}
+
+	for _, fragment := range expectedFragments {
+		if !strings.Contains(report, fragment) {
+			t.Errorf("expected report to contain %q\n\n%s", fragment, report)
+		}
+	}
+
+	if strings.Contains(report, "<script>") {
+		t.Errorf("expected urls to be escaped in the report")
+	}
+}
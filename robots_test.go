@@ -0,0 +1,211 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCrawlRobotsDirectives(t *testing.T) {
+	testServer := newRobotsTestServer()
+	defer testServer.Close()
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		expectedPaths []string
+	}{
+		{
+			name: "respect directives",
+			expectedPaths: []string{
+				"",
+				"/", "/follow", "/meta-nofollow", "/noindex",
+				"/robots-none",
+			},
+		},
+		{
+			name: "ignore nofollow",
+			opts: []Option{SetIgnoreNoFollow(true)},
+			expectedPaths: []string{
+				"",
+				"/", "/follow", "/header-hidden", "/meta-hidden",
+				"/meta-nofollow", "/noindex", "/rel-nofollow",
+				"/robots-none",
+			},
+		},
+		{
+			name: "ignore noindex",
+			opts: []Option{SetIgnoreNoIndex(true)},
+			expectedPaths: []string{
+				"",
+				"/", "/follow", "/meta-nofollow", "/noindex",
+				"/robots-none",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := append([]Option{
+				SetClient(testServer.Client()),
+				SetLogger(zap.NewNop()),
+			}, test.opts...)
+
+			sitemap, err := CrawlDomain(testServer.URL, opts...)
+			if err != nil {
+				t.Fatalf("error crawling robots site: %q", err)
+			}
+
+			var crawled []string
+			for _, page := range sitemap.Pages() {
+				crawled = append(crawled, page.URL[len(testServer.URL):])
+			}
+			if !reflect.DeepEqual(crawled, test.expectedPaths) {
+				t.Errorf(
+					"expected crawled paths %v but got %v",
+					test.expectedPaths,
+					crawled,
+				)
+			}
+		})
+	}
+}
+
+func TestRobotsSiteMapOutput(t *testing.T) {
+	testServer := newRobotsTestServer()
+	defer testServer.Close()
+
+	for _, ignoreNoIndex := range []bool{false, true} {
+		sitemap, err := CrawlDomain(
+			testServer.URL,
+			SetClient(testServer.Client()),
+			SetLogger(zap.NewNop()),
+			SetIgnoreNoIndex(ignoreNoIndex),
+		)
+		if err != nil {
+			t.Fatalf("error crawling robots site: %q", err)
+		}
+
+		var textOut, xmlOut bytes.Buffer
+		sitemap.WriteMap(&textOut)
+		if err := sitemap.WriteXML(&xmlOut); err != nil {
+			t.Fatalf("error writing xml: %q", err)
+		}
+
+		noIndexURL := []byte(testServer.URL + "/noindex\n")
+		if bytes.Contains(textOut.Bytes(), noIndexURL) != ignoreNoIndex {
+			t.Errorf(
+				"unexpected noindex page in text output with ignore %v:\n%s",
+				ignoreNoIndex,
+				textOut.String(),
+			)
+		}
+
+		noIndexLoc := []byte("<loc>" + testServer.URL + "/noindex</loc>")
+		if bytes.Contains(xmlOut.Bytes(), noIndexLoc) != ignoreNoIndex {
+			t.Errorf(
+				"unexpected noindex page in xml output with ignore %v:\n%s",
+				ignoreNoIndex,
+				xmlOut.String(),
+			)
+		}
+
+		for _, page := range sitemap.Pages() {
+			path := page.URL[len(testServer.URL):]
+			expectNoIndex := path == "/noindex" || path == "/robots-none"
+			if page.NoIndex != expectNoIndex {
+				t.Errorf("expected noindex %v for %s", expectNoIndex, path)
+			}
+		}
+	}
+}
+
+func TestHasToken(t *testing.T) {
+	tests := []struct {
+		list     string
+		token    string
+		expected bool
+	}{
+		{"noindex, nofollow", "nofollow", true},
+		{"NOINDEX,NOFOLLOW", "noindex", true},
+		{"noarchive nofollow", "nofollow", true},
+		{"external noopener", "nofollow", false},
+		{"googlebot: noindex", "noindex", true},
+		{"", "noindex", false},
+	}
+
+	for _, test := range tests {
+		if hasToken(test.list, test.token) != test.expected {
+			t.Errorf(
+				"expected hasToken(%q, %q) to be %v",
+				test.list,
+				test.token,
+				test.expected,
+			)
+		}
+	}
+}
+
+// newRobotsTestServer serves a small site exercising robots directives.
+func newRobotsTestServer() *httptest.Server {
+	pages := map[string]string{
+		"/": `<html><body>
+			<a href="/follow">follow</a>
+			<a rel="external nofollow" href="/rel-nofollow">rel</a>
+			<a href="/meta-nofollow">meta</a>
+			<a href="/noindex">noindex</a>
+		</body></html>`,
+		"/follow": `<html><body><a href="/">home</a></body></html>`,
+		"/rel-nofollow": `<html><body>
+			<a href="/robots-none">hidden</a>
+		</body></html>`,
+		"/meta-nofollow": `<html><head>
+			<meta name="Robots" content="NOFOLLOW">
+		</head><body><a href="/meta-hidden">hidden</a></body></html>`,
+		"/noindex": `<html><head>
+			<meta name="robots" content="noindex">
+		</head><body><a href="/robots-none">none</a></body></html>`,
+		"/robots-none":   `<html><body><a href="/header-hidden">hidden</a></body></html>`,
+		"/meta-hidden":   `<html><body></body></html>`,
+		"/header-hidden": `<html><body></body></html>`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, ok := pages[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if r.URL.Path == "/robots-none" {
+				w.Header().Set("X-Robots-Tag", "none")
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(body))
+		},
+	))
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCrawlMultipleRoots(t *testing.T) {
+	var lock sync.Mutex
+	requests := map[string]int{}
+
+	newServer := func(body func() string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				lock.Lock()
+				requests[r.Host+r.URL.Path]++
+				lock.Unlock()
+
+				w.Header().Set("Content-Type", "text/html")
+				io.WriteString(w, body())
+			},
+		))
+	}
+
+	var docsURL string
+	shop := newServer(func() string {
+		return `<a href="/cart">cart</a><a href="` + docsURL + `/faq">faq</a>`
+	})
+	defer shop.Close()
+
+	docs := newServer(func() string {
+		return `<a href="/faq">faq</a><a href="http://external.com/">x</a>`
+	})
+	defer docs.Close()
+	docsURL = docs.URL
+
+	sitemap, err := CrawlDomains(
+		[]string{shop.URL, docs.URL, shop.URL},
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	expectedURLs := []string{
+		docs.URL,
+		docs.URL + "/faq",
+		shop.URL,
+		shop.URL + "/cart",
+	}
+	sort.Strings(expectedURLs)
+	if urls := sitemap.URLs(); !reflect.DeepEqual(urls, expectedURLs) {
+		t.Errorf("expected urls %v but got %v", expectedURLs, urls)
+	}
+
+	expectedRoots := []string{shop.URL, docs.URL}
+	if !reflect.DeepEqual(sitemap.Roots(), expectedRoots) {
+		t.Errorf("expected roots %v but got %v", expectedRoots, sitemap.Roots())
+	}
+
+	for path, count := range requests {
+		if count != 1 {
+			t.Errorf("expected %s to be fetched once but got %d", path, count)
+		}
+	}
+
+	var snapshot bytes.Buffer
+	if err := sitemap.WriteJSON(&snapshot); err != nil {
+		t.Fatalf("error writing snapshot: %q", err)
+	}
+
+	loaded, err := ReadSiteMap(&snapshot)
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	if !reflect.DeepEqual(loaded.Roots(), expectedRoots) {
+		t.Errorf(
+			"expected roots %v after round trip but got %v",
+			expectedRoots,
+			loaded.Roots(),
+		)
+	}
+}
+
+func TestCrawlRootsLinkingToEachOther(t *testing.T) {
+	var lock sync.Mutex
+	requests := map[string]int{}
+
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requests[r.URL.Path]++
+			lock.Unlock()
+
+			w.Header().Set("Content-Type", "text/html")
+			switch r.URL.Path {
+			case "/a":
+				io.WriteString(w, `<a href="/b">b</a><a href="/x">x</a>`)
+			case "/b":
+				io.WriteString(w, `<a href="/a">a</a>`)
+			}
+		},
+	))
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomains(
+		[]string{testServer.URL + "/a", testServer.URL + "/b"},
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	expectedRequests := map[string]int{"/a": 1, "/b": 1, "/x": 1}
+	if !reflect.DeepEqual(requests, expectedRequests) {
+		t.Errorf("expected requests %v but got %v", expectedRequests, requests)
+	}
+
+	for _, page := range sitemap.Pages() {
+		path := strings.TrimPrefix(page.URL, testServer.URL)
+		if path == "/x" {
+			continue
+		}
+
+		if page.Depth != 0 || page.Referrer != "" {
+			t.Errorf(
+				"expected root %s at depth 0 without referrer but got %d and %q",
+				path,
+				page.Depth,
+				page.Referrer,
+			)
+		}
+
+		if page.StatusCode != http.StatusOK {
+			t.Errorf("expected root %s to be recorded with its status", path)
+		}
+	}
+}
+
+func TestCrawlNoRoots(t *testing.T) {
+	expectedErr := "at least one root url is required"
+
+	_, err := CrawlDomains(nil, SetLogger(zap.NewNop()))
+
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q but got %q", expectedErr, err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// changeFrequencies are the valid changefreq values of the sitemap protocol.
+var changeFrequencies = map[string]bool{
+	"always":  true,
+	"hourly":  true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+	"yearly":  true,
+	"never":   true,
+}
+
+// SitemapRule sets the changefreq and priority of the pages in the XML output
+// whose URL matches the pattern. An empty change frequency or a nil priority
+// leaves the value of the page unchanged.
+type SitemapRule struct {
+	Pattern    *regexp.Regexp
+	ChangeFreq string
+	Priority   *float64
+}
+
+// ReadSitemapRules reads sitemap rules with one rule per line in the form
+//
+//	<pattern> <changefreq> [priority]
+//
+// where the pattern is a regular expression matched against the page URL. A
+// changefreq or priority of "-" is left unset. Blank lines and lines starting
+// with # are ignored.
+func ReadSitemapRules(in io.Reader) ([]SitemapRule, error) {
+	var rules []SitemapRule
+
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseSitemapRule(line)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"invalid sitemap rule on line %d: %s",
+				lineNumber,
+				err,
+			)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func parseSitemapRule(line string) (SitemapRule, error) {
+	var rule SitemapRule
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return rule, fmt.Errorf(
+			"expected <pattern> <changefreq> [priority] but got %q",
+			line,
+		)
+	}
+
+	pattern, err := regexp.Compile(fields[0])
+	if err != nil {
+		return rule, err
+	}
+	rule.Pattern = pattern
+
+	if fields[1] != "-" {
+		if !changeFrequencies[fields[1]] {
+			return rule, fmt.Errorf("unknown changefreq %q", fields[1])
+		}
+		rule.ChangeFreq = fields[1]
+	}
+
+	if len(fields) == 3 && fields[2] != "-" {
+		priority, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || priority < 0 || priority > 1 {
+			return rule, fmt.Errorf(
+				"priority must be between 0 and 1 but got %q",
+				fields[2],
+			)
+		}
+		rule.Priority = &priority
+	}
+
+	return rule, nil
+}
+
+// matchSitemapRule returns the first rule that matches the URL, or nil if no
+// rule matches.
+func matchSitemapRule(rules []SitemapRule, urlString string) *SitemapRule {
+	for i := range rules {
+		if rules[i].Pattern.MatchString(urlString) {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadSitemapRules(t *testing.T) {
+	rules, err := ReadSitemapRules(strings.NewReader(`
+# news changes often
+^https://example\.com/news/  hourly  0.8
+
+/archive/ yearly
+/about$ - 0.3
+/drafts/ never 0.0
+`))
+	if err != nil {
+		t.Fatalf("error reading rules: %q", err)
+	}
+
+	if len(rules) != 4 {
+		t.Fatalf("expected 4 rules but got %d", len(rules))
+	}
+
+	// A priority of -1 is expected to be left unset
+	tests := []struct {
+		url        string
+		changeFreq string
+		priority   float64
+	}{
+		{"https://example.com/news/today", "hourly", 0.8},
+		{"https://example.com/archive/2019", "yearly", -1},
+		{"https://example.com/about", "", 0.3},
+		{"https://example.com/drafts/a", "never", 0},
+	}
+
+	for _, test := range tests {
+		rule := matchSitemapRule(rules, test.url)
+		if rule == nil {
+			t.Errorf("expected a rule to match %s", test.url)
+			continue
+		}
+
+		priority := -1.0
+		if rule.Priority != nil {
+			priority = *rule.Priority
+		}
+
+		if rule.ChangeFreq != test.changeFreq || priority != test.priority {
+			t.Errorf(
+				"expected %s to match rule %q %v but got %q %v",
+				test.url,
+				test.changeFreq,
+				test.priority,
+				rule.ChangeFreq,
+				priority,
+			)
+		}
+	}
+
+	if matchSitemapRule(rules, "https://example.com/contact") != nil {
+		t.Errorf("expected no rule to match")
+	}
+}
+
+func TestReadSitemapRulesInvalid(t *testing.T) {
+	tests := []struct {
+		rules       string
+		expectedErr string
+	}{
+		{
+			"/a",
+			"invalid sitemap rule on line 1: " +
+				`expected <pattern> <changefreq> [priority] but got "/a"`,
+		},
+		{
+			"\n/a sometimes",
+			`invalid sitemap rule on line 2: unknown changefreq "sometimes"`,
+		},
+		{
+			"/a daily 1.5",
+			"invalid sitemap rule on line 1: " +
+				`priority must be between 0 and 1 but got "1.5"`,
+		},
+		{
+			"/a daily -0.1",
+			"invalid sitemap rule on line 1: " +
+				`priority must be between 0 and 1 but got "-0.1"`,
+		},
+		{
+			"[ daily",
+			"invalid sitemap rule on line 1: " +
+				"error parsing regexp: missing closing ]: `[`",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := ReadSitemapRules(strings.NewReader(test.rules))
+		if err == nil || err.Error() != test.expectedErr {
+			t.Errorf("expected error %q but got %q", test.expectedErr, err)
+		}
+	}
+}
@@ -23,11 +23,15 @@ package sitemapper
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"net/url"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -39,6 +43,28 @@ import (
 // hrefAttr is used for matching the 'href' attribute in an 'a' tag.
 var hrefAttr = []byte("href")
 
+// relAttr and hreflangAttr are used for matching the attributes of a 'link'
+// tag.
+var relAttr = []byte("rel")
+var hreflangAttr = []byte("hreflang")
+
+// titleTag and linkTag are used for matching page metadata tags.
+var titleTag = []byte("title")
+var linkTag = []byte("link")
+
+// imgTag, pictureTag and sourceTag are used for matching images and their
+// 'src' and 'srcset' attributes.
+var imgTag = []byte("img")
+var pictureTag = []byte("picture")
+var sourceTag = []byte("source")
+var srcAttr = []byte("src")
+var srcsetAttr = []byte("srcset")
+
+// metaTag, nameAttr and contentAttr are used for matching robots meta tags.
+var metaTag = []byte("meta")
+var nameAttr = []byte("name")
+var contentAttr = []byte("content")
+
 // CrawlDomain crawls a domain provided as a string URL. It wraps a call to
 // CrawlDomainWithURL.
 func CrawlDomain(rootURL string, opts ...Option) (*SiteMap, error) {
@@ -52,9 +78,31 @@ func CrawlDomain(rootURL string, opts ...Option) (*SiteMap, error) {
 // CrawlDomainWithURL crawls a domain provided as a URL and returns the
 // resulting sitemap.
 func CrawlDomainWithURL(root *url.URL, opts ...Option) (*SiteMap, error) {
+	return CrawlDomainsWithURLs([]*url.URL{root}, opts...)
+}
+
+// CrawlDomains crawls from several root URLs provided as strings into a single
+// site map. It wraps a call to CrawlDomainsWithURLs.
+func CrawlDomains(rootURLs []string, opts ...Option) (*SiteMap, error) {
+	roots := make([]*url.URL, 0, len(rootURLs))
+	for _, rootURL := range rootURLs {
+		root, rootErr := url.Parse(rootURL)
+		if rootErr != nil {
+			return nil, rootErr
+		}
+		roots = append(roots, root)
+	}
+
+	return CrawlDomainsWithURLs(roots, opts...)
+}
+
+// CrawlDomainsWithURLs crawls from several root URLs, possibly on different
+// hosts, and returns the combined site map. Links are crawled when the domain
+// validator accepts them for any of the roots.
+func CrawlDomainsWithURLs(roots []*url.URL, opts ...Option) (*SiteMap, error) {
 	config := NewConfig(opts...)
 
-	crawler, crawlerError := NewDomainCrawler(root, config)
+	crawler, crawlerError := NewMultiDomainCrawler(roots, config)
 	if crawlerError != nil {
 		return nil, crawlerError
 	}
@@ -65,47 +113,114 @@ func CrawlDomainWithURL(root *url.URL, opts ...Option) (*SiteMap, error) {
 // DomainCrawler contains the state of a domain web crawler. The domain crawler
 // exposes a Crawl method which proudces a site map.
 type DomainCrawler struct {
-	root                 *url.URL
-	config               *Config
-	siteMap              *SiteMap
-	pendingURLS          chan *url.URL
-	pendingURLSRemaining *sync.WaitGroup
-	accessedPageCount    atomic.Uint64
-	timedOut             atomic.Bool
+	roots             []*url.URL
+	config            *Config
+	siteMap           *SiteMap
+	pendingURLS       *urlQueue
+	concurrency       *concurrencyLimiter
+	requestHeader     http.Header
+	authRequestHeader http.Header
+	stats             *crawlStats
+	timedOut          atomic.Bool
+	abortLock         sync.Mutex
+	abortErr          error
+	loginLock         sync.Mutex
+	logins            int
 }
 
 // NewDomainCrawler creates a new DomainCrawler from the root url and given
 // configuration.
 func NewDomainCrawler(root *url.URL, config *Config) (*DomainCrawler, error) {
+	return NewMultiDomainCrawler([]*url.URL{root}, config)
+}
+
+// NewMultiDomainCrawler creates a new DomainCrawler that starts from each of
+// the root urls and combines the results into a single site map. Duplicate
+// roots are crawled once.
+func NewMultiDomainCrawler(
+	roots []*url.URL,
+	config *Config,
+) (*DomainCrawler, error) {
 	configError := config.Validate()
 	if configError != nil {
 		return nil, configError
 	}
 
-	siteMap := NewSiteMap(root, config.DomainValidator)
+	roots = uniqueURLs(roots)
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("at least one root url is required")
+	}
+
+	if len(roots) > config.MaxPendingURLS {
+		return nil, fmt.Errorf(
+			"config.MaxPendingURLS must be at least the number of roots (%d)",
+			len(roots),
+		)
+	}
+
+	siteMap := NewSiteMapWithRoots(roots, config.DomainValidator)
+	siteMap.metadata.IgnoreNoIndex = config.IgnoreNoIndex
+
+	// Roots are in the site map from the start, so that links between roots
+	// are not crawled again and roots that fail to load are still reported.
+	for _, root := range roots {
+		rootString := root.String()
+		siteMap.siteURLS[rootString] = &Page{URL: rootString, Depth: 0}
+	}
+
+	if config.CookieJar != nil {
+		setCookies(config.CookieJar, roots, config.Cookies)
+	}
 
-	pendingURLS := make(chan *url.URL, config.MaxPendingURLS)
-	pendingURLS <- root
+	pendingURLS := newURLQueue(config.MaxPendingURLS, config.MaxHostConcurrency)
+	if config.BreakerThreshold > 0 {
+		pendingURLS.newBreaker = func(origin string) *circuitBreaker {
+			return &circuitBreaker{
+				origin:    origin,
+				threshold: config.BreakerThreshold,
+				cooldown:  config.BreakerCooldown,
+				deadline:  config.BreakerDeadline,
+			}
+		}
+	}
 
-	var pendingURLSRemaining sync.WaitGroup
-	pendingURLSRemaining.Add(1)
+	for _, root := range roots {
+		pendingURLS.push(root)
+	}
 
 	return &DomainCrawler{
-		root:                 root,
-		config:               config,
-		siteMap:              siteMap,
-		pendingURLS:          pendingURLS,
-		pendingURLSRemaining: &pendingURLSRemaining,
+		roots:       roots,
+		config:      config,
+		siteMap:     siteMap,
+		pendingURLS: pendingURLS,
+		concurrency: newConcurrencyLimiter(
+			config.MaxConcurrency,
+			config.AdaptiveConcurrency,
+			config.Logger,
+		),
+		stats:             newCrawlStats(),
+		requestHeader:     config.requestHeader(),
+		authRequestHeader: config.authRequestHeader(),
 	}, nil
 }
 
 // Crawl reads all links in the domain with the specified concurrency and
-// returns a site map. Note that Crawl is not thread safe and each caller must
-// create a separate DomainCrawler.
+// returns a site map. If the circuit breaker of an origin is still open past
+// its deadline, the crawl is aborted with an OriginDownError. Note that Crawl
+// is not thread safe and each caller must create a separate DomainCrawler.
 func (crawler *DomainCrawler) Crawl() (*SiteMap, error) {
 	maxConcurrency := crawler.config.MaxConcurrency
 	crawlTimeout := crawler.config.CrawlTimeout
 
+	crawler.siteMap.metadata.StartTime = time.Now().UTC()
+	crawler.concurrency.start(crawler.siteMap.metadata.StartTime)
+
+	if crawler.config.LoginForm != nil {
+		if err := crawler.relogin(0); err != nil {
+			return nil, err
+		}
+	}
+
 	for i := 0; i < maxConcurrency; i++ {
 		go crawler.drainURLS()
 	}
@@ -117,26 +232,45 @@ func (crawler *DomainCrawler) Crawl() (*SiteMap, error) {
 		go func() {
 			time.Sleep(crawlTimeout)
 			crawler.timedOut.Store(true)
+			crawler.pendingURLS.expire()
 		}()
 	}
 
-	crawler.pendingURLSRemaining.Wait()
-	close(crawler.pendingURLS)
+	crawler.pendingURLS.wait()
+	crawler.siteMap.settleDepths()
+
+	crawler.siteMap.metadata.EndTime = time.Now().UTC()
+	crawler.siteMap.metadata.TimedOut = crawler.timedOut.Load()
+	crawler.siteMap.stats = crawler.Stats()
+
+	if err := crawler.abortError(); err != nil {
+		return nil, err
+	}
 
-	if crawler.accessedPageCount.Load() == 0 {
-		return nil, fmt.Errorf("unable to access url %s", crawler.root.String())
+	if crawler.stats.pagesFetched() == 0 {
+		return nil, fmt.Errorf(
+			"unable to access url %s",
+			crawler.siteMap.describeRoots(),
+		)
 	}
 
 	return crawler.siteMap, nil
 }
 
-// drainURLS reads from the the pending URLS channel and crawls the page for
-// more links
+// drainURLS reads from the the pending URLS queue and crawls the page for
+// more links. Pages are only taken from the queue while the number of pages
+// being crawled is below the concurrency limit.
 func (crawler *DomainCrawler) drainURLS() {
-	client := crawler.config.Client
 	logger := crawler.config.Logger
 
-	for pageURL := range crawler.pendingURLS {
+	for {
+		crawler.concurrency.acquire()
+		pageURL, ok := crawler.pendingURLS.pop()
+		if !ok {
+			crawler.concurrency.release()
+			return
+		}
+
 		logger.Debug("crawling page for links",
 			zap.String("url", pageURL.String()),
 		)
@@ -146,18 +280,141 @@ func (crawler *DomainCrawler) drainURLS() {
 				zap.String("url", pageURL.String()),
 			)
 		} else {
-			linkReader := NewLinkReader(pageURL, client)
-			crawler.realAllLinks(linkReader)
-			linkReader.Close()
+			start := time.Now()
+			linkReader, readErr := crawler.readPage(pageURL)
+			duration := time.Since(start)
+
+			crawler.concurrency.observe(
+				duration,
+				linkReader.StatusCode(),
+				readErr,
+			)
+			crawler.reportOutcome(
+				pageURL,
+				isOverloaded(linkReader.StatusCode(), readErr),
+			)
+			crawler.stats.recordPage(
+				linkReader,
+				duration,
+				crawler.errorType(linkReader, readErr),
+			)
+
+			page := crawler.siteMap.recordPage(linkReader, duration, readErr)
+			if crawler.config.PageHandler != nil {
+				crawler.config.PageHandler.HandlePage(page)
+			}
 		}
 
-		crawler.pendingURLSRemaining.Done()
+		crawler.pendingURLS.done(pageURL)
+		crawler.concurrency.release()
 	}
 }
 
+// reportOutcome updates the circuit breaker of the origin of the page. The
+// crawl is aborted if the origin has been failing past the breaker deadline.
+func (crawler *DomainCrawler) reportOutcome(pageURL *url.URL, failed bool) {
+	logger := crawler.config.Logger
+
+	event, err := crawler.pendingURLS.report(pageURL, failed)
+	switch event {
+	case breakerOpened:
+		logger.Warn("circuit breaker opened, pausing crawl of origin",
+			zap.String("origin", hostKey(pageURL)),
+			zap.Duration("cooldown", crawler.config.BreakerCooldown),
+		)
+	case breakerClosed:
+		logger.Info("circuit breaker closed, resuming crawl of origin",
+			zap.String("origin", hostKey(pageURL)),
+		)
+	}
+
+	if err != nil {
+		crawler.abort(err)
+	}
+}
+
+// abort stops the crawl with the error. Pending URLs are dropped and the
+// pages being crawled are allowed to finish.
+func (crawler *DomainCrawler) abort(err error) {
+	crawler.abortLock.Lock()
+	if crawler.abortErr == nil {
+		crawler.abortErr = err
+		crawler.config.Logger.Error("aborting crawl", zap.Error(err))
+	}
+	crawler.abortLock.Unlock()
+
+	crawler.pendingURLS.stop()
+}
+
+// abortError returns the error that aborted the crawl, if any.
+func (crawler *DomainCrawler) abortError() error {
+	crawler.abortLock.Lock()
+	defer crawler.abortLock.Unlock()
+
+	return crawler.abortErr
+}
+
+// readPage reads all links from the page. If the login session has expired
+// the login form is submitted again and the page is read once more.
+func (crawler *DomainCrawler) readPage(pageURL *url.URL) (*LinkReader, error) {
+	for attempt := 0; ; attempt++ {
+		loginCount := crawler.loginCount()
+
+		linkReader := NewLinkReader(pageURL, crawler.config.Client)
+		linkReader.acceptImage = crawler.acceptImage
+		linkReader.skipNoFollow = !crawler.config.IgnoreNoFollow
+		linkReader.header = crawler.pageRequestHeader(pageURL)
+		readErr := crawler.realAllLinks(linkReader)
+		linkReader.Close()
+
+		if readErr != errSessionExpired || attempt > 0 {
+			return linkReader, readErr
+		}
+
+		crawler.config.Logger.Info("login session expired",
+			zap.String("url", pageURL.String()),
+		)
+		if err := crawler.relogin(loginCount); err != nil {
+			return linkReader, err
+		}
+	}
+}
+
+// pageRequestHeader returns the headers to send with the request for the page.
+// Credentials are only included for URLs accepted by the domain validator.
+func (crawler *DomainCrawler) pageRequestHeader(pageURL *url.URL) http.Header {
+	if crawler.siteMap.inScope(pageURL) {
+		return crawler.authRequestHeader
+	}
+
+	return crawler.requestHeader
+}
+
+// acceptImage returns true if the image should be kept in the site map. When
+// same domain images are required, images must be in the domain of a root or
+// on one of the configured image hosts.
+func (crawler *DomainCrawler) acceptImage(imageURL *url.URL) bool {
+	if !crawler.config.SameDomainImages {
+		return true
+	}
+
+	if crawler.siteMap.inScope(imageURL) {
+		return true
+	}
+
+	for _, host := range crawler.config.ImageHosts {
+		if strings.EqualFold(imageURL.Host, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // readAllLinks pushes all previously unseen links from the given linkReader
-// into the domain crawler's pending URL channel for crawling.
-func (crawler *DomainCrawler) realAllLinks(linkReader *LinkReader) {
+// into the domain crawler's pending URL queue for crawling. The error that
+// stopped the page from being read is returned, if any.
+func (crawler *DomainCrawler) realAllLinks(linkReader *LinkReader) error {
 	logger := crawler.config.Logger
 
 	for {
@@ -173,11 +430,17 @@ func (crawler *DomainCrawler) realAllLinks(linkReader *LinkReader) {
 					zap.String("page", linkReader.URL()),
 					zap.Error(hrefErr),
 				)
+				return hrefErr
 			}
-			break
+			crawler.readDeclaredLinks(linkReader)
+			return nil
+		}
+
+		if crawler.isSessionExpired(linkReader) {
+			return errSessionExpired
 		}
 
-		crawler.accessedPageCount.Add(1)
+		crawler.stats.recordLink()
 
 		hrefURL, hrefParseErr := url.Parse(hrefString)
 		if hrefParseErr != nil {
@@ -194,31 +457,67 @@ func (crawler *DomainCrawler) realAllLinks(linkReader *LinkReader) {
 		// page. URLs such as "?a=123" are rooted in the current path
 		hrefResolved := linkReader.pageURL.ResolveReference(hrefURL)
 
-		if crawler.siteMap.appendURL(hrefResolved) {
+		if !crawler.siteMap.inScope(hrefResolved) {
+			crawler.stats.recordOutOfScope()
+		} else if !crawler.siteMap.appendURL(hrefResolved, linkReader.pageURL) {
+			crawler.stats.recordDuplicate()
+		} else {
 			logger.Debug("found new page",
 				zap.String("page", hrefResolved.String()),
 			)
-			// Note that if we were to do blocking writes here, the
-			// buffered channel could be full and the write would block
-			// here. If all goroutines were blocked on writing to the
-			// channel this would deadlock.
-			select {
-			case crawler.pendingURLS <- hrefResolved:
-				logger.Debug("page appended to channel",
-					zap.String("page", hrefResolved.String()),
-				)
-				crawler.pendingURLSRemaining.Add(1)
-			default:
-				// If the buffered channel is full we ran out of memory
-				logger.Error("too many pending urls, page will be ignored",
-					zap.String("page", hrefResolved.String()),
-					zap.String("link", linkReader.URL()),
-				)
-			}
+			crawler.pushURL(hrefResolved, linkReader)
+		}
+	}
+}
+
+// readDeclaredLinks pushes the canonical URL and the hreflang alternates
+// declared by the page into the pending URL queue when they are in scope and
+// unseen, so that they are checked even when no link points to them.
+func (crawler *DomainCrawler) readDeclaredLinks(linkReader *LinkReader) {
+	var declared []string
+	if linkReader.Canonical() != "" {
+		declared = append(declared, linkReader.Canonical())
+	}
+	for _, alternate := range linkReader.Alternates() {
+		declared = append(declared, alternate.URL)
+	}
+
+	for _, declaredString := range declared {
+		declaredURL, err := url.Parse(declaredString)
+		if err != nil {
+			continue
+		}
+
+		if crawler.siteMap.appendDeclaredURL(declaredURL, linkReader.pageURL) {
+			crawler.config.Logger.Debug("found new declared page",
+				zap.String("page", declaredURL.String()),
+			)
+			crawler.pushURL(declaredURL, linkReader)
 		}
 	}
 }
 
+// pushURL pushes a new page found on the page of the link reader into the
+// pending URL queue.
+func (crawler *DomainCrawler) pushURL(pageURL *url.URL, linkReader *LinkReader) {
+	logger := crawler.config.Logger
+
+	// Note that pushing never blocks. If all goroutines were
+	// blocked waiting for space in the queue this would deadlock.
+	if crawler.pendingURLS.push(pageURL) {
+		logger.Debug("page appended to queue",
+			zap.String("page", pageURL.String()),
+		)
+	} else if crawler.abortError() == nil {
+		// If the queue is full we ran out of memory
+		crawler.stats.recordQueueFull()
+		logger.Error("too many pending urls, page will be ignored",
+			zap.String("page", pageURL.String()),
+			zap.String("link", linkReader.URL()),
+		)
+	}
+}
+
 // A DomainValidator provides a Validate functions for comparing two URLs
 // for same domain inclusion. This allows for custom behavior such as checking
 // scheme (http vs https) or DNS lookup.
@@ -245,32 +544,56 @@ func ValidateHosts(root, link *url.URL) bool {
 // SiteMap contains the state of a site map.
 type SiteMap struct {
 	url       *url.URL
+	roots     []*url.URL
 	rwl       *sync.RWMutex
-	siteURLS  map[string]bool
+	siteURLS  map[string]*Page
+	inlinks   map[string]map[string]bool
 	validator DomainValidator
+	metadata  CrawlMetadata
+
+	// stats are the statistics of the crawl that produced the site map.
+	stats Stats
+
+	// validatorDesc describes the validator of a site map that was decoded
+	// from JSON, where the original validator is not available.
+	validatorDesc string
 }
 
 // NewSiteMap initializes a new SiteMap anchored at the specified URL and
 // crawls with the specified HTTP client
-func NewSiteMap(url *url.URL, validator DomainValidator) *SiteMap {
+func NewSiteMap(root *url.URL, validator DomainValidator) *SiteMap {
+	return NewSiteMapWithRoots([]*url.URL{root}, validator)
+}
+
+// NewSiteMapWithRoots initializes a new SiteMap anchored at several root URLs.
+// URLs are part of the site map when the validator accepts them for any of
+// the roots. The first root is the primary root of the site map.
+func NewSiteMapWithRoots(roots []*url.URL, validator DomainValidator) *SiteMap {
 	return &SiteMap{
-		url:       url,
+		url:       roots[0],
+		roots:     roots,
 		rwl:       &sync.RWMutex{},
-		siteURLS:  map[string]bool{},
+		siteURLS:  map[string]*Page{},
+		inlinks:   map[string]map[string]bool{},
 		validator: validator,
 	}
 }
 
 // appendURL returns true if the url should be crawled. If true is returned
 // it is assumed that the caller will crawl this URL and subsequent calls to
-// appendURL will return false.
-func (s *SiteMap) appendURL(url *url.URL) bool {
+// appendURL will return false. The referrer is the page the url was found on
+// and is recorded as an inlink of the url.
+func (s *SiteMap) appendURL(url *url.URL, referrer *url.URL) bool {
 	// We shouldn't crawl if the url is not valid or is in an external domain
-	if !s.validator.Validate(s.url, url) {
+	if !s.inScope(url) {
 		return false
 	}
 
 	urlString := url.String()
+	referrerString := referrer.String()
+
+	// Links from a page to itself are not counted as inlinks
+	isInlink := referrerString != urlString
 
 	// We could always lock over a normal mutex, but by using a RWMutex
 	// we should increase the throughput of checking duplicate urls.
@@ -278,10 +601,11 @@ func (s *SiteMap) appendURL(url *url.URL) bool {
 	// navigation bar for example), so it's a reasonable to expect that many
 	// calls to shouldCrawl will not yield write contention.
 	s.rwl.RLock()
-	maybeCrawl := !s.siteURLS[urlString]
+	maybeCrawl := s.siteURLS[urlString] == nil
+	maybeInlink := isInlink && !s.inlinks[urlString][referrerString]
 	s.rwl.RUnlock()
 
-	if !maybeCrawl {
+	if !maybeCrawl && !maybeInlink {
 		return false
 	}
 
@@ -289,14 +613,278 @@ func (s *SiteMap) appendURL(url *url.URL) bool {
 	// in a race condition, so reading again is necessary after acquiring the
 	// write lock.
 	s.rwl.Lock()
-	crawl := !s.siteURLS[urlString]
-	s.siteURLS[urlString] = true
-	s.rwl.Unlock()
-	return crawl
+	defer s.rwl.Unlock()
+
+	if isInlink {
+		s.addInlink(urlString, referrerString)
+	}
+
+	// Roots are in the site map at depth 0, so a referrer that is not in the
+	// site map is treated as a root
+	depth := 1
+	if referrerPage := s.siteURLS[referrerString]; referrerPage != nil {
+		depth = referrerPage.Depth + 1
+	}
+
+	page := s.siteURLS[urlString]
+	if page == nil {
+		s.siteURLS[urlString] = &Page{
+			URL:      urlString,
+			Depth:    depth,
+			Referrer: referrerString,
+		}
+		return true
+	}
+
+	// Workers find links in any order, so a page may be reached by a shorter
+	// path after it was added
+	if isInlink && depth < page.Depth {
+		page.Depth = depth
+		page.Referrer = referrerString
+	}
+
+	return false
 }
 
-// WriteMap writes the ordered site map to a given writer.
-func (s *SiteMap) WriteMap(out io.Writer) {
+// appendDeclaredURL returns true if a url declared by the referrer, rather than
+// linked to, should be crawled. Unlike appendURL it does not record an inlink,
+// the referrer is only kept until a link to the url is found.
+func (s *SiteMap) appendDeclaredURL(url *url.URL, referrer *url.URL) bool {
+	if !s.inScope(url) {
+		return false
+	}
+
+	urlString := url.String()
+
+	s.rwl.Lock()
+	defer s.rwl.Unlock()
+
+	if s.siteURLS[urlString] != nil {
+		return false
+	}
+
+	depth := 1
+	if referrerPage := s.siteURLS[referrer.String()]; referrerPage != nil {
+		depth = referrerPage.Depth + 1
+	}
+
+	s.siteURLS[urlString] = &Page{
+		URL:      urlString,
+		Depth:    depth,
+		Referrer: referrer.String(),
+	}
+
+	return true
+}
+
+// settleDepths sets the depth of every page to its shortest click depth from
+// a root, following the links recorded as inlinks. Depths found while
+// crawling can be too deep when a page was reached through a longer path
+// before a shorter one, and pages found below it keep the longer path. The
+// referrer is kept when it is on a shortest path, otherwise it is replaced by
+// the first such referrer in order.
+func (s *SiteMap) settleDepths() {
+	s.rwl.Lock()
+	defer s.rwl.Unlock()
+
+	links := map[string][]string{}
+	for urlString, referrers := range s.inlinks {
+		for referrer := range referrers {
+			links[referrer] = append(links[referrer], urlString)
+		}
+	}
+
+	depths := map[string]int{}
+	var queue []string
+	for _, root := range s.Roots() {
+		if _, ok := depths[root]; !ok {
+			depths[root] = 0
+			queue = append(queue, root)
+		}
+	}
+
+	for len(queue) > 0 {
+		urlString := queue[0]
+		queue = queue[1:]
+
+		for _, link := range links[urlString] {
+			if _, ok := depths[link]; !ok {
+				depths[link] = depths[urlString] + 1
+				queue = append(queue, link)
+			}
+		}
+	}
+
+	// Pages declared by another page, such as a canonical URL, keep that
+	// page as the referrer until a link to them is found
+	onShortestPath := func(urlString string, referrer string) bool {
+		referrerDepth, ok := depths[referrer]
+		return ok && referrerDepth == depths[urlString]-1 &&
+			s.inlinks[urlString][referrer]
+	}
+
+	for urlString, page := range s.siteURLS {
+		depth, ok := depths[urlString]
+		if !ok || depth == 0 {
+			continue
+		}
+
+		page.Depth = depth
+		if onShortestPath(urlString, page.Referrer) {
+			continue
+		}
+
+		var referrers []string
+		for referrer := range s.inlinks[urlString] {
+			if onShortestPath(urlString, referrer) {
+				referrers = append(referrers, referrer)
+			}
+		}
+		sort.Strings(referrers)
+		if len(referrers) > 0 {
+			page.Referrer = referrers[0]
+		}
+	}
+}
+
+// inScope returns true if the validator accepts the url for any of the roots.
+func (s *SiteMap) inScope(link *url.URL) bool {
+	for _, root := range s.roots {
+		if s.validator.Validate(root, link) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Roots returns the root URLs of the site map.
+func (s *SiteMap) Roots() []string {
+	roots := make([]string, len(s.roots))
+	for i, root := range s.roots {
+		roots[i] = root.String()
+	}
+
+	return roots
+}
+
+// describeRoots returns the root URLs joined by commas, each followed by the
+// error that occurred fetching it, if any.
+func (s *SiteMap) describeRoots() string {
+	s.rwl.RLock()
+	defer s.rwl.RUnlock()
+
+	roots := make([]string, len(s.roots))
+	for i, root := range s.roots {
+		roots[i] = root.String()
+		if page := s.siteURLS[roots[i]]; page != nil && page.Error != "" {
+			roots[i] += ": " + page.Error
+		}
+	}
+
+	return strings.Join(roots, ", ")
+}
+
+// uniqueURLs returns the urls without duplicates, keeping the first of each.
+func uniqueURLs(urls []*url.URL) []*url.URL {
+	seen := make(map[string]bool, len(urls))
+	unique := make([]*url.URL, 0, len(urls))
+	for _, u := range urls {
+		if !seen[u.String()] {
+			seen[u.String()] = true
+			unique = append(unique, u)
+		}
+	}
+
+	return unique
+}
+
+// addInlink records that the referrer links to the url. The caller must hold
+// the write lock.
+func (s *SiteMap) addInlink(urlString string, referrerString string) {
+	referrers := s.inlinks[urlString]
+	if referrers == nil {
+		referrers = map[string]bool{}
+		s.inlinks[urlString] = referrers
+	}
+	referrers[referrerString] = true
+}
+
+// copyPage returns a copy of the page with the sorted list of inlinks. The
+// caller must hold the read lock.
+func (s *SiteMap) copyPage(page *Page) Page {
+	pageCopy := *page
+
+	referrers := s.inlinks[page.URL]
+	if len(referrers) > 0 {
+		pageCopy.Inlinks = make([]string, 0, len(referrers))
+		for referrer := range referrers {
+			pageCopy.Inlinks = append(pageCopy.Inlinks, referrer)
+		}
+		sort.Strings(pageCopy.Inlinks)
+	}
+
+	return pageCopy
+}
+
+// recordPage stores the result of fetching a page with the link reader and
+// returns a copy of the updated page. Results for URLs which are not part of
+// the site map, such as the root, are returned but not stored.
+func (s *SiteMap) recordPage(
+	linkReader *LinkReader,
+	duration time.Duration,
+	err error,
+) Page {
+	s.rwl.Lock()
+	defer s.rwl.Unlock()
+
+	urlString := linkReader.URL()
+	page := s.siteURLS[urlString]
+	if page == nil {
+		page = &Page{URL: urlString}
+	}
+
+	page.StatusCode = linkReader.StatusCode()
+	page.ContentType = linkReader.ContentType()
+	page.Size = linkReader.Size()
+	page.Duration = duration
+	page.Redirect = linkReader.Redirect()
+	page.Title = linkReader.Title()
+	page.Canonical = linkReader.Canonical()
+	page.Images = linkReader.Images()
+	page.Alternates = linkReader.Alternates()
+	page.NoIndex = linkReader.NoIndex()
+	page.NoFollow = linkReader.NoFollow()
+	page.ContentHash = linkReader.ContentHash()
+	page.LastModified = nil
+	if lastModified := linkReader.LastModified(); !lastModified.IsZero() {
+		page.LastModified = &lastModified
+	}
+	if err != nil {
+		page.Error = err.Error()
+	}
+
+	return s.copyPage(page)
+}
+
+// Pages returns a copy of the pages in the site map ordered by URL.
+func (s *SiteMap) Pages() []Page {
+	s.rwl.RLock()
+	defer s.rwl.RUnlock()
+
+	pages := make([]Page, 0, len(s.siteURLS))
+	for _, page := range s.siteURLS {
+		pages = append(pages, s.copyPage(page))
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].URL < pages[j].URL
+	})
+
+	return pages
+}
+
+// URLs returns the sorted list of URLs in the site map.
+func (s *SiteMap) URLs() []string {
 	s.rwl.RLock()
 	defer s.rwl.RUnlock()
 
@@ -306,12 +894,28 @@ func (s *SiteMap) WriteMap(out io.Writer) {
 	}
 	sort.Strings(paths)
 
-	for _, path := range paths {
-		io.WriteString(out, path)
+	return paths
+}
+
+// WriteMap writes the ordered site map to a given writer. Pages that ask not
+// to be indexed are left out unless the crawl was configured to ignore noindex
+// directives.
+func (s *SiteMap) WriteMap(out io.Writer) {
+	for _, page := range s.Pages() {
+		if s.isExcludedNoIndex(&page) {
+			continue
+		}
+		io.WriteString(out, page.URL)
 		io.WriteString(out, "\n")
 	}
 }
 
+// isExcludedNoIndex returns true if the page asks not to be indexed and should
+// be left out of the site map output.
+func (s *SiteMap) isExcludedNoIndex(page *Page) bool {
+	return page.NoIndex && !s.metadata.IgnoreNoIndex
+}
+
 // LinkReader is an iterative structure that allows for reading all href tags
 // in a given URL. The link reader will make the http request to the specified
 // url and allow for reading through all links in the returned page. When there
@@ -319,11 +923,37 @@ func (s *SiteMap) WriteMap(out io.Writer) {
 // responsible for closing the LinkReader when done to ensure and client http
 // requests are cleaned up.
 type LinkReader struct {
-	client   *http.Client
-	pageURL  *url.URL
-	response *http.Response
-	doc      *html.Tokenizer
-	done     bool
+	client     *http.Client
+	pageURL    *url.URL
+	response   *http.Response
+	body       *countingReader
+	doc        *html.Tokenizer
+	done       bool
+	redirect   string
+	title      string
+	canonical  string
+	images     []string
+	inPicture  bool
+	alternates []Alternate
+	noIndex    bool
+	noFollow   bool
+
+	// requestErr is the error of the request for the page, if it failed.
+	requestErr error
+
+	// noFollowSkipped counts the links that were skipped as nofollow.
+	noFollowSkipped int
+
+	// skipNoFollow skips links marked as nofollow, either by the rel
+	// attribute of the link or by the robots directives of the page.
+	skipNoFollow bool
+
+	// header is sent with the request for the page.
+	header http.Header
+
+	// acceptImage filters the images of the page. All images are accepted
+	// when it is nil.
+	acceptImage func(imageURL *url.URL) bool
 }
 
 // NewLinkReader returns a LinkReader for the specified URL, fetching the
@@ -342,13 +972,27 @@ func (u *LinkReader) Read() (string, error) {
 	}
 
 	if u.doc == nil {
-		resp, respErr := u.client.Get(u.pageURL.String())
+		req, reqErr := http.NewRequest(http.MethodGet, u.pageURL.String(), nil)
+		if reqErr != nil {
+			return "", fmt.Errorf("http get error: %q", reqErr)
+		}
+		for name, values := range u.header {
+			req.Header[name] = values
+		}
+
+		resp, respErr := u.client.Do(req)
 		if respErr != nil {
+			u.requestErr = respErr
 			return "", fmt.Errorf("http get error: %q", respErr)
 		}
 
 		u.response = resp
-		u.doc = html.NewTokenizer(resp.Body)
+		u.body = &countingReader{reader: resp.Body, hash: sha256.New()}
+		u.doc = html.NewTokenizer(u.body)
+
+		for _, directives := range resp.Header["X-Robots-Tag"] {
+			u.addRobotsDirectives(directives)
+		}
 
 		// If the response is a redirect we should read the location header
 		// It is valid for 201 to return a location header but this should
@@ -362,11 +1006,13 @@ func (u *LinkReader) Read() (string, error) {
 				return "", err
 			}
 			u.done = true
-			return locationURL.String(), nil
+			u.redirect = locationURL.String()
+			return u.redirect, nil
 		}
 	}
 
-	// Read the href attributes from all a tags using a streaming tokenizer
+	// Read the href attributes from all a tags using a streaming tokenizer.
+	// Page metadata found along the way is kept on the reader.
 	for {
 		tt := u.doc.Next()
 		switch tt {
@@ -375,25 +1021,237 @@ func (u *LinkReader) Read() (string, error) {
 				return "", closeErr
 			}
 			return "", u.doc.Err()
-		case html.StartTagToken:
+		case html.EndTagToken:
+			tn, _ := u.doc.TagName()
+			if bytes.Equal(tn, pictureTag) {
+				u.inPicture = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
 			tn, hasAttr := u.doc.TagName()
-			if len(tn) == 1 && tn[0] == 'a' && hasAttr {
+			if len(tn) == 1 && tn[0] == 'a' && hasAttr &&
+				tt == html.StartTagToken {
 
-				// Read the href attribute from the link
+				// Read the href and rel attributes from the link
+				var href string
+				var hasHref, noFollow bool
 				for {
 					key, val, moreAttr := u.doc.TagAttr()
-					if bytes.Equal(key, hrefAttr) {
-						return string(val), nil
+					if bytes.Equal(key, hrefAttr) && !hasHref {
+						href, hasHref = string(val), true
+					} else if bytes.Equal(key, relAttr) {
+						noFollow = hasToken(string(val), "nofollow")
 					}
 					if !moreAttr {
 						break
 					}
 				}
+
+				if hasHref && u.skipNoFollow && (noFollow || u.noFollow) {
+					u.noFollowSkipped++
+				} else if hasHref {
+					return href, nil
+				}
+			} else if bytes.Equal(tn, metaTag) && hasAttr {
+				u.readMetaTag()
+			} else if bytes.Equal(tn, titleTag) && tt == html.StartTagToken {
+				u.readTitle()
+			} else if bytes.Equal(tn, linkTag) && hasAttr {
+				u.readLinkTag()
+			} else if bytes.Equal(tn, pictureTag) && tt == html.StartTagToken {
+				u.inPicture = true
+			} else if bytes.Equal(tn, imgTag) && hasAttr {
+				u.readImageTag()
+			} else if bytes.Equal(tn, sourceTag) && hasAttr && u.inPicture {
+				u.readImageTag()
 			}
 		}
 	}
 }
 
+// readTitle reads the text of a title tag. Only the first title is kept.
+func (u *LinkReader) readTitle() {
+	if u.doc.Next() != html.TextToken || u.title != "" {
+		return
+	}
+
+	u.title = strings.Join(strings.Fields(string(u.doc.Text())), " ")
+}
+
+// readLinkTag reads the attributes of a link tag, keeping the canonical URL
+// and the localized alternates of the page.
+func (u *LinkReader) readLinkTag() {
+	var rel, href, hreflang string
+	for {
+		key, val, moreAttr := u.doc.TagAttr()
+		if bytes.Equal(key, relAttr) {
+			rel = string(val)
+		} else if bytes.Equal(key, hrefAttr) {
+			href = string(val)
+		} else if bytes.Equal(key, hreflangAttr) {
+			hreflang = strings.TrimSpace(string(val))
+		}
+		if !moreAttr {
+			break
+		}
+	}
+
+	if href == "" {
+		return
+	}
+
+	for _, relType := range strings.Fields(rel) {
+		switch {
+		case strings.EqualFold(relType, "canonical") && u.canonical == "":
+			u.canonical = u.resolve(href)
+		case strings.EqualFold(relType, "alternate") && hreflang != "":
+			u.alternates = append(u.alternates, Alternate{
+				Hreflang: hreflang,
+				URL:      u.resolve(href),
+			})
+		}
+	}
+}
+
+// readMetaTag reads the robots directives of the page from a meta tag.
+func (u *LinkReader) readMetaTag() {
+	var name, content string
+	for {
+		key, val, moreAttr := u.doc.TagAttr()
+		if bytes.Equal(key, nameAttr) {
+			name = string(val)
+		} else if bytes.Equal(key, contentAttr) {
+			content = string(val)
+		}
+		if !moreAttr {
+			break
+		}
+	}
+
+	if strings.EqualFold(strings.TrimSpace(name), "robots") {
+		u.addRobotsDirectives(content)
+	}
+}
+
+// addRobotsDirectives records the noindex and nofollow directives from a
+// robots meta tag or X-Robots-Tag header. The none directive implies both.
+func (u *LinkReader) addRobotsDirectives(directives string) {
+	if hasToken(directives, "noindex") || hasToken(directives, "none") {
+		u.noIndex = true
+	}
+	if hasToken(directives, "nofollow") || hasToken(directives, "none") {
+		u.noFollow = true
+	}
+}
+
+// hasToken returns true if the comma or space separated list contains the
+// token, ignoring case.
+func hasToken(list string, token string) bool {
+	for _, field := range strings.FieldsFunc(list, isTokenSeparator) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTokenSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// readImageTag reads the image URLs from the src and srcset attributes of an
+// img tag or the source tag of a picture.
+func (u *LinkReader) readImageTag() {
+	for {
+		key, val, moreAttr := u.doc.TagAttr()
+		if bytes.Equal(key, srcAttr) {
+			u.addImage(string(val))
+		} else if bytes.Equal(key, srcsetAttr) {
+			for _, src := range parseSrcset(string(val)) {
+				u.addImage(src)
+			}
+		}
+		if !moreAttr {
+			break
+		}
+	}
+}
+
+// parseSrcset returns the URLs of the image candidates in a srcset attribute.
+// Each candidate is a URL followed by optional descriptors and candidates are
+// separated by commas. URLs themselves may contain commas, so a URL is read up
+// to the next whitespace.
+func parseSrcset(srcset string) []string {
+	var urls []string
+
+	for {
+		srcset = strings.TrimLeft(srcset, ", \t\n\r\f")
+		if srcset == "" {
+			return urls
+		}
+
+		end := strings.IndexAny(srcset, " \t\n\r\f")
+		if end == -1 {
+			end = len(srcset)
+		}
+
+		// A URL directly followed by a comma has no descriptors
+		src := strings.TrimRight(srcset[:end], ",")
+		if src != "" {
+			urls = append(urls, src)
+		}
+		if strings.HasSuffix(srcset[:end], ",") {
+			srcset = srcset[end:]
+			continue
+		}
+
+		// Skip the descriptors of the candidate
+		next := strings.IndexByte(srcset[end:], ',')
+		if next == -1 {
+			return urls
+		}
+		srcset = srcset[end+next:]
+	}
+}
+
+// addImage resolves the image URL and keeps it if it is accepted and has not
+// been seen on the page before.
+func (u *LinkReader) addImage(src string) {
+	src = strings.TrimSpace(src)
+	if src == "" || strings.HasPrefix(src, "data:") {
+		return
+	}
+
+	srcURL, err := url.Parse(src)
+	if err != nil {
+		return
+	}
+
+	imageURL := u.pageURL.ResolveReference(srcURL)
+	if u.acceptImage != nil && !u.acceptImage(imageURL) {
+		return
+	}
+
+	imageString := imageURL.String()
+	for _, image := range u.images {
+		if image == imageString {
+			return
+		}
+	}
+
+	u.images = append(u.images, imageString)
+}
+
+// resolve resolves a link relative to the page URL. Links which can't be
+// parsed are returned unmodified.
+func (u *LinkReader) resolve(link string) string {
+	linkURL, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return link
+	}
+
+	return u.pageURL.ResolveReference(linkURL).String()
+}
+
 // Close cleans up any remaining client response. If all links are read from
 // the link reader the body will be automatically closed, however if only the
 // first N links are required, the body must be closed by the caller.
@@ -406,7 +1264,121 @@ func (u *LinkReader) Close() error {
 	return nil
 }
 
+// StatusCode returns the status code of the http response, or 0 if no response
+// has been received.
+func (u *LinkReader) StatusCode() int {
+	if u.response == nil {
+		return 0
+	}
+
+	return u.response.StatusCode
+}
+
+// ContentType returns the content type header of the http response, or an
+// empty string if no response has been received.
+func (u *LinkReader) ContentType() string {
+	if u.response == nil {
+		return ""
+	}
+
+	return u.response.Header.Get("Content-Type")
+}
+
+// Size returns the number of bytes of the response body read so far.
+func (u *LinkReader) Size() int64 {
+	if u.body == nil {
+		return 0
+	}
+
+	return u.body.count
+}
+
+// Redirect returns the resolved location of a redirect response, or an empty
+// string if the response was not a redirect.
+func (u *LinkReader) Redirect() string {
+	return u.redirect
+}
+
+// Title returns the title of the page, if one has been read.
+func (u *LinkReader) Title() string {
+	return u.title
+}
+
+// Canonical returns the resolved canonical URL declared by the page with a
+// link tag, if one has been read.
+func (u *LinkReader) Canonical() string {
+	return u.canonical
+}
+
+// Images returns the resolved URLs of the images on the page that have been
+// read, in the order they were found.
+func (u *LinkReader) Images() []string {
+	return u.images
+}
+
+// Alternates returns the localized alternates of the page declared with link
+// tags that have been read.
+func (u *LinkReader) Alternates() []Alternate {
+	return u.alternates
+}
+
+// NoIndex returns true if the page asked not to be indexed, with a robots meta
+// tag or an X-Robots-Tag header.
+func (u *LinkReader) NoIndex() bool {
+	return u.noIndex
+}
+
+// NoFollow returns true if the page asked for its links not to be followed,
+// with a robots meta tag or an X-Robots-Tag header.
+func (u *LinkReader) NoFollow() bool {
+	return u.noFollow
+}
+
+// LastModified returns the time of the Last-Modified header of the http
+// response, or the zero time if there is no valid header.
+func (u *LinkReader) LastModified() time.Time {
+	if u.response == nil {
+		return time.Time{}
+	}
+
+	lastModified, err := http.ParseTime(u.response.Header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}
+	}
+
+	return lastModified.UTC()
+}
+
+// ContentHash returns the hex encoded SHA-256 hash of the response body, or an
+// empty string if the body has not been read to the end.
+func (u *LinkReader) ContentHash() string {
+	if u.body == nil || !u.body.eof {
+		return ""
+	}
+
+	return hex.EncodeToString(u.body.hash.Sum(nil))
+}
+
 // URL returns the read-only url string that was used to make the client request
 func (u *LinkReader) URL() string {
 	return u.pageURL.String()
 }
+
+// countingReader counts and hashes the bytes read from the underlying reader
+// and records when the end of the reader has been reached.
+type countingReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	count  int64
+	eof    bool
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	c.hash.Write(p[:n])
+	if err == io.EOF {
+		c.eof = true
+	}
+	return n, err
+}
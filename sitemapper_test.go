@@ -23,11 +23,14 @@ package sitemapper
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -39,8 +42,11 @@ var directoryHandler http.HandlerFunc = nil
 
 // The expected site map string for the example
 var expectedSiteMap = []string{
+	// The root url itself
+	"",
 	"/",
 	"/about",
+	"/fr/about",
 	"/hidden",
 	"/hidden?t=0",
 	"/images",
@@ -51,6 +57,7 @@ var expectedSiteMap = []string{
 
 // The expected site map when the links are truncated by max pending
 var expectedTruncatedSiteMap = []string{
+	"",
 	"/",
 	"/about",
 	"/images",
@@ -180,11 +187,387 @@ func TestCrawlTimeout(t *testing.T) {
 	}
 }
 
+func TestPageHandler(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	var lock sync.Mutex
+	handled := map[string]Page{}
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+		SetPageHandler(PageHandlerFunc(func(page Page) {
+			lock.Lock()
+			handled[page.URL] = page
+			lock.Unlock()
+		})),
+	)
+
+	if err != nil {
+		t.Fatalf("error reading example site map: %q", err)
+	}
+
+	// Every page in the site map is handled, including the root
+	if len(handled) != len(expectedSiteMap) {
+		t.Errorf(
+			"expected %d pages to be handled but got %d",
+			len(expectedSiteMap),
+			len(handled),
+		)
+	}
+
+	if root := handled[testServer.URL]; root.Depth != 0 || root.Referrer != "" {
+		t.Errorf("unexpected root page %v", root)
+	}
+
+	expectedDepths := map[string]int{
+		"":            0,
+		"/":           1,
+		"/about":      1,
+		"/fr/about":   2,
+		"/hidden":     2,
+		"/hidden?t=0": 3,
+		"/images":     1,
+		"/rectangle":  2,
+		"/secret":     1,
+		"/square":     2,
+	}
+
+	for _, page := range sitemap.Pages() {
+		path := strings.TrimPrefix(page.URL, testServer.URL)
+
+		// Inlinks are still being discovered when a page is handled, and
+		// the depth and referrer are settled once the crawl has finished
+		handledPage := handled[page.URL]
+		handledPage.Inlinks = page.Inlinks
+		handledPage.Depth = page.Depth
+		handledPage.Referrer = page.Referrer
+
+		if !reflect.DeepEqual(page, handledPage) {
+			t.Errorf(
+				"expected handled page %v to match site map page %v",
+				handled[page.URL],
+				page,
+			)
+		}
+
+		if page.Depth != expectedDepths[path] {
+			t.Errorf(
+				"expected depth %d for %s but got %d",
+				expectedDepths[path],
+				path,
+				page.Depth,
+			)
+		}
+
+		if page.Referrer == "" && path != "" {
+			t.Errorf("expected referrer for %s", path)
+		}
+
+		if page.StatusCode == http.StatusOK &&
+			!strings.HasPrefix(page.ContentType, "text/html") {
+			t.Errorf(
+				"expected html content type for %s but got %q",
+				path,
+				page.ContentType,
+			)
+		}
+	}
+}
+
+func TestCrawlImages(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected []string
+	}{
+		{
+			name: "all images",
+			expected: []string{
+				testServer.URL + "/images/wide.webp",
+				testServer.URL + "/images/narrow.webp",
+				testServer.URL + "/images/fallback.jpg",
+				"https://cdn.picsum.photos/200/200",
+				"https://cdn.picsum.photos/400/400",
+			},
+		},
+		{
+			name: "same domain images",
+			opts: []Option{SetSameDomainImages(true)},
+			expected: []string{
+				testServer.URL + "/images/wide.webp",
+				testServer.URL + "/images/narrow.webp",
+				testServer.URL + "/images/fallback.jpg",
+			},
+		},
+		{
+			name: "same domain and cdn images",
+			opts: []Option{
+				SetSameDomainImages(true),
+				SetImageHosts("cdn.picsum.photos"),
+			},
+			expected: []string{
+				testServer.URL + "/images/wide.webp",
+				testServer.URL + "/images/narrow.webp",
+				testServer.URL + "/images/fallback.jpg",
+				"https://cdn.picsum.photos/200/200",
+				"https://cdn.picsum.photos/400/400",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		sitemap, err := CrawlDomain(testServer.URL, append(
+			[]Option{
+				SetClient(testServer.Client()),
+				SetLogger(zap.NewNop()),
+			},
+			test.opts...,
+		)...)
+
+		if err != nil {
+			t.Fatalf("%s: error reading example site map: %q", test.name, err)
+		}
+
+		for _, page := range sitemap.Pages() {
+			if page.URL != testServer.URL+"/images" {
+				continue
+			}
+
+			if !reflect.DeepEqual(page.Images, test.expected) {
+				t.Errorf(
+					"%s: expected images %v but got %v",
+					test.name,
+					test.expected,
+					page.Images,
+				)
+			}
+		}
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	tests := map[string][]string{
+		"":                          nil,
+		"a.jpg":                     {"a.jpg"},
+		"a.jpg 1x, b.jpg 2x":        {"a.jpg", "b.jpg"},
+		"a.jpg, b.jpg":              {"a.jpg", "b.jpg"},
+		" a.jpg 100w , b.jpg 200w ": {"a.jpg", "b.jpg"},
+		"data:image/gif;base64,R0lG 1x, c.jpg 2x": {
+			"data:image/gif;base64,R0lG",
+			"c.jpg",
+		},
+	}
+
+	for srcset, expected := range tests {
+		urls := parseSrcset(srcset)
+		if !reflect.DeepEqual(urls, expected) {
+			t.Errorf(
+				"expected %v for srcset %q but got %v",
+				expected,
+				srcset,
+				urls,
+			)
+		}
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	var lock sync.Mutex
+	var received []http.Header
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			received = append(received, r.Header)
+			lock.Unlock()
+
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<a href="/page">page</a>`)
+		},
+	))
+	defer testServer.Close()
+
+	_, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+		SetUserAgent("sitemapper-test/1.0"),
+		SetHeader("Accept-Language", "fr"),
+		SetHeader("X-Token", "a"),
+		SetHeader("X-Token", "b"),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("expected 2 requests but got %d", len(received))
+	}
+
+	for _, header := range received {
+		if header.Get("User-Agent") != "sitemapper-test/1.0" {
+			t.Errorf("expected user agent but got %q", header.Get("User-Agent"))
+		}
+		if header.Get("Accept-Language") != "fr" {
+			t.Errorf("expected accept language header in %v", header)
+		}
+		if !reflect.DeepEqual(header["X-Token"], []string{"a", "b"}) {
+			t.Errorf("expected repeated header values in %v", header)
+		}
+	}
+}
+
+func TestCredentialsOnlySentToValidatedURLs(t *testing.T) {
+	var lock sync.Mutex
+	authorization := make(map[string]string)
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			authorization[r.URL.Path] = r.Header.Get("Authorization")
+			lock.Unlock()
+
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<a href="/private">private</a>`)
+		},
+	))
+	defer testServer.Close()
+
+	// The root is crawled without being accepted by the validator, so it
+	// acts as a URL outside of the domain.
+	validator := DomainValidatorFunc(func(root, link *url.URL) bool {
+		return link.Path != ""
+	})
+
+	_, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+		SetDomainValidator(validator),
+		SetBasicAuth("user", "secret"),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	expected := map[string]string{
+		"/":        "",
+		"/private": "Basic dXNlcjpzZWNyZXQ=",
+	}
+	if !reflect.DeepEqual(authorization, expected) {
+		t.Errorf(
+			"expected authorization headers %v but got %v",
+			expected,
+			authorization,
+		)
+	}
+}
+
+func TestCrawlWithResolve(t *testing.T) {
+	var lock sync.Mutex
+	var hosts []string
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			hosts = append(hosts, r.Host)
+			lock.Unlock()
+
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<a href="/page">page</a>`)
+		},
+	))
+	defer testServer.Close()
+
+	serverURL, _ := url.Parse(testServer.URL)
+	host := "www.example.com:" + serverURL.Port()
+
+	sitemap, err := CrawlDomain(
+		"http://"+host,
+		SetLogger(zap.NewNop()),
+		SetResolve(host, serverURL.Hostname()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	expectedURLs := []string{"http://" + host, "http://" + host + "/page"}
+	if !reflect.DeepEqual(sitemap.URLs(), expectedURLs) {
+		t.Errorf("expected urls %v but got %v", expectedURLs, sitemap.URLs())
+	}
+
+	for _, requestHost := range hosts {
+		if requestHost != host {
+			t.Errorf("expected host header %s but got %s", host, requestHost)
+		}
+	}
+}
+
+func TestCrawlShortestDepth(t *testing.T) {
+	// /x is linked from /a at depth 1 but /a is slow, so /x is first found
+	// through /b and /c. /y is found below /x before /x gets a shorter path.
+	links := map[string]string{
+		"/":  `<a href="/a">a</a><a href="/b">b</a>`,
+		"/a": `<a href="/x">x</a>`,
+		"/b": `<a href="/c">c</a>`,
+		"/c": `<a href="/x">x</a>`,
+		"/x": `<a href="/y">y</a>`,
+	}
+
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/a" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, links[r.URL.Path])
+		},
+	))
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(testServer.URL+"/", SetLogger(zap.NewNop()))
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	type depth struct {
+		Depth    int
+		Referrer string
+	}
+	expected := map[string]depth{
+		"/":  {0, ""},
+		"/a": {1, "/"},
+		"/b": {1, "/"},
+		"/c": {2, "/b"},
+		"/x": {2, "/a"},
+		"/y": {3, "/x"},
+	}
+
+	for _, page := range sitemap.Pages() {
+		path := strings.TrimPrefix(page.URL, testServer.URL)
+		actual := depth{
+			page.Depth,
+			strings.TrimPrefix(page.Referrer, testServer.URL),
+		}
+		if actual != expected[path] {
+			t.Errorf(
+				"expected depth and referrer %v for %s but got %v",
+				expected[path],
+				path,
+				actual,
+			)
+		}
+	}
+}
+
 func TestCrawlError(t *testing.T) {
 	testServer := newTestServer()
 	testServer.Close()
 
-	expectedError := fmt.Sprintf("unable to access url %s", testServer.URL)
+	expectedError := fmt.Sprintf("unable to access url %s: ", testServer.URL)
 
 	_, err := CrawlDomain(
 		testServer.URL,
@@ -192,7 +575,7 @@ func TestCrawlError(t *testing.T) {
 		SetLogger(zap.NewNop()),
 	)
 
-	if err == nil || err.Error() != expectedError {
+	if err == nil || !strings.HasPrefix(err.Error(), expectedError) {
 		t.Errorf("expected error reading site map got %q", err)
 	}
 }
@@ -0,0 +1,193 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SiteMapSchemaVersion is the version of the JSON encoding of a SiteMap. It is
+// incremented whenever a change to the encoding is not backwards compatible.
+const SiteMapSchemaVersion = 1
+
+// CrawlMetadata describes the crawl that produced a site map. IgnoreNoIndex
+// records whether pages that ask not to be indexed are kept in the site map
+// output.
+type CrawlMetadata struct {
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+	TimedOut      bool      `json:"timedOut"`
+	IgnoreNoIndex bool      `json:"ignoreNoIndex,omitempty"`
+}
+
+// Duration returns the time taken by the crawl.
+func (m CrawlMetadata) Duration() time.Duration {
+	return m.EndTime.Sub(m.StartTime)
+}
+
+// siteMapJSON is the versioned JSON encoding of a SiteMap.
+type siteMapJSON struct {
+	Version   int           `json:"version"`
+	Root      string        `json:"root"`
+	Roots     []string      `json:"roots,omitempty"`
+	Validator string        `json:"validator"`
+	Metadata  CrawlMetadata `json:"metadata"`
+	URLs      []Page        `json:"urls"`
+}
+
+// Metadata returns information about the crawl that produced the site map.
+func (s *SiteMap) Metadata() CrawlMetadata {
+	return s.metadata
+}
+
+// MarshalJSON implements json.Marshaler. The encoding contains the schema
+// version, the root URL, a description of the domain validator, the crawl
+// metadata and the pages ordered by URL. Site maps with several roots also
+// list all of the roots.
+func (s *SiteMap) MarshalJSON() ([]byte, error) {
+	var roots []string
+	if len(s.roots) > 1 {
+		roots = s.Roots()
+	}
+
+	return json.Marshal(siteMapJSON{
+		Version:   SiteMapSchemaVersion,
+		Root:      s.url.String(),
+		Roots:     roots,
+		Validator: s.validatorName(),
+		Metadata:  s.metadata,
+		URLs:      s.Pages(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Validators can't be serialized,
+// so the decoded site map keeps the validator description for reference but
+// uses the default domain validator.
+func (s *SiteMap) UnmarshalJSON(data []byte) error {
+	var decoded siteMapJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	if decoded.Version != SiteMapSchemaVersion {
+		return fmt.Errorf(
+			"unsupported site map schema version %d",
+			decoded.Version,
+		)
+	}
+
+	root, rootErr := url.Parse(decoded.Root)
+	if rootErr != nil {
+		return rootErr
+	}
+
+	roots := []*url.URL{root}
+	if len(decoded.Roots) > 0 {
+		roots = make([]*url.URL, 0, len(decoded.Roots))
+		for _, rootString := range decoded.Roots {
+			root, rootErr := url.Parse(rootString)
+			if rootErr != nil {
+				return rootErr
+			}
+			roots = append(roots, root)
+		}
+	}
+
+	siteURLS := make(map[string]*Page, len(decoded.URLs))
+	inlinks := map[string]map[string]bool{}
+	for i := range decoded.URLs {
+		page := decoded.URLs[i]
+		siteURLS[page.URL] = &page
+
+		if len(page.Inlinks) > 0 {
+			referrers := make(map[string]bool, len(page.Inlinks))
+			for _, referrer := range page.Inlinks {
+				referrers[referrer] = true
+			}
+			inlinks[page.URL] = referrers
+			page.Inlinks = nil
+		}
+	}
+
+	*s = SiteMap{
+		url:           roots[0],
+		roots:         roots,
+		rwl:           &sync.RWMutex{},
+		siteURLS:      siteURLS,
+		inlinks:       inlinks,
+		validator:     DomainValidatorFunc(ValidateHosts),
+		validatorDesc: decoded.Validator,
+		metadata:      decoded.Metadata,
+	}
+
+	return nil
+}
+
+// WriteJSON writes the JSON encoding of the site map to the writer. The site
+// map can be read back with ReadSiteMap.
+func (s *SiteMap) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// ReadSiteMap reads the JSON encoding of a site map written by WriteJSON.
+func ReadSiteMap(in io.Reader) (*SiteMap, error) {
+	var siteMap SiteMap
+	if err := json.NewDecoder(in).Decode(&siteMap); err != nil {
+		return nil, fmt.Errorf("invalid site map snapshot: %s", err)
+	}
+
+	return &siteMap, nil
+}
+
+// validatorName describes the domain validator of the site map. Validators
+// may implement fmt.Stringer to provide a description, otherwise the name of
+// the function or type is used.
+func (s *SiteMap) validatorName() string {
+	if s.validatorDesc != "" {
+		return s.validatorDesc
+	}
+
+	return describeValidator(s.validator)
+}
+
+// describeValidator describes a domain validator with its String method, or
+// with the name of the function or type.
+func describeValidator(validator DomainValidator) string {
+	switch v := validator.(type) {
+	case nil:
+		return ""
+	case fmt.Stringer:
+		return v.String()
+	case DomainValidatorFunc:
+		return runtime.FuncForPC(reflect.ValueOf(v).Pointer()).Name()
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
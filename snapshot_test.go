@@ -0,0 +1,176 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCrawlRecordsStatus(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error reading example site map: %q", err)
+	}
+
+	for _, page := range sitemap.Pages() {
+		expectedStatus := http.StatusOK
+		if page.URL == testServer.URL+"/secret" {
+			expectedStatus = http.StatusMovedPermanently
+		}
+
+		if page.StatusCode != expectedStatus {
+			t.Errorf(
+				"expected status %d for %s but got %d",
+				expectedStatus,
+				page.URL,
+				page.StatusCode,
+			)
+		}
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error reading example site map: %q", err)
+	}
+
+	var snapshot bytes.Buffer
+	if err := sitemap.WriteJSON(&snapshot); err != nil {
+		t.Fatalf("error writing snapshot: %q", err)
+	}
+
+	loaded, err := ReadSiteMap(&snapshot)
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	if !reflect.DeepEqual(loaded.Pages(), sitemap.Pages()) {
+		t.Errorf(
+			"unexpected pages after round trip.\n\nGot:\n\n%v\n\nExpected:\n\n%v",
+			loaded.Pages(),
+			sitemap.Pages(),
+		)
+	}
+
+	if loaded.url.String() != sitemap.url.String() {
+		t.Errorf("expected root %s but got %s", sitemap.url, loaded.url)
+	}
+
+	if loaded.Metadata() != sitemap.Metadata() {
+		t.Errorf(
+			"expected metadata %v but got %v",
+			sitemap.Metadata(),
+			loaded.Metadata(),
+		)
+	}
+
+	expectedValidator := "github.com/Matt-Esch/sitemapper.ValidateHosts"
+	if loaded.validatorName() != expectedValidator {
+		t.Errorf(
+			"expected validator %q but got %q",
+			expectedValidator,
+			loaded.validatorName(),
+		)
+	}
+}
+
+func TestSiteMapJSONSchema(t *testing.T) {
+	root, _ := url.Parse("http://example.com")
+	sitemap := NewSiteMap(root, hostValidator("example.com"))
+	sitemap.appendURL(root.ResolveReference(&url.URL{Path: "/a"}), root)
+
+	encoded, err := json.Marshal(sitemap)
+	if err != nil {
+		t.Fatalf("error encoding site map: %q", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("error decoding site map: %q", err)
+	}
+
+	expectedKeys := []string{"version", "root", "validator", "metadata", "urls"}
+	for _, key := range expectedKeys {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("expected key %q in encoded site map %s", key, encoded)
+		}
+	}
+
+	if decoded["validator"] != "host example.com" {
+		t.Errorf(
+			"expected stringer validator description but got %q",
+			decoded["validator"],
+		)
+	}
+}
+
+func TestReadSiteMapUnsupportedVersion(t *testing.T) {
+	expectedErr := "invalid site map snapshot: " +
+		"unsupported site map schema version 2"
+
+	_, err := ReadSiteMap(strings.NewReader(`{"version": 2, "root": ""}`))
+
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected unsupported version error but got %q", err)
+	}
+}
+
+// hostValidator is a domain validator that describes itself.
+type hostValidator string
+
+func (h hostValidator) Validate(root, link *url.URL) bool {
+	return link.Host == string(h)
+}
+
+func (h hostValidator) String() string {
+	return "host " + string(h)
+}
+
+func TestReadSiteMapInvalid(t *testing.T) {
+	_, err := ReadSiteMap(strings.NewReader("not json"))
+
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid site map snapshot") {
+		t.Errorf("expected invalid snapshot error but got %q", err)
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stats summarizes the work done by a crawl.
+//
+// PagesFetched counts the requests that got a response and BytesDownloaded
+// the size of their bodies. LinksDiscovered counts every link read from the
+// pages, of which DuplicatesSkipped were already in the site map and the
+// dropped counts were not crawled because they were out of scope, marked
+// nofollow or did not fit in the pending URL queue. StatusCodes counts the
+// responses by status code and Errors counts the failed pages by type of
+// error. Concurrency lists the concurrency chosen over time when adaptive
+// concurrency is enabled.
+type Stats struct {
+	PagesFetched      int                 `json:"pagesFetched"`
+	BytesDownloaded   int64               `json:"bytesDownloaded"`
+	LinksDiscovered   int                 `json:"linksDiscovered"`
+	DuplicatesSkipped int                 `json:"duplicatesSkipped"`
+	DroppedOutOfScope int                 `json:"droppedOutOfScope"`
+	DroppedNoFollow   int                 `json:"droppedNoFollow"`
+	DroppedQueueFull  int                 `json:"droppedQueueFull"`
+	StatusCodes       map[int]int         `json:"statusCodes"`
+	Errors            map[string]int      `json:"errors"`
+	Latency           LatencyPercentiles  `json:"latency"`
+	Concurrency       []ConcurrencySample `json:"concurrency,omitempty"`
+}
+
+// LatencyPercentiles describes the distribution of the time taken to fetch
+// the pages that got a response.
+type LatencyPercentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Types of error counted in Stats.Errors.
+const (
+	ErrorTypeTimeout        = "timeout"
+	ErrorTypeBlocked        = "blocked"
+	ErrorTypeRequest        = "request"
+	ErrorTypeRead           = "read"
+	ErrorTypeSessionExpired = "session_expired"
+	ErrorTypeLogin          = "login"
+)
+
+// Stats returns the statistics of the crawl that produced the site map. They
+// are empty for site maps read from a snapshot.
+func (s *SiteMap) Stats() Stats {
+	return s.stats.copy()
+}
+
+// Stats returns the statistics of the crawl so far. It is safe to call while
+// the crawl is running, and after a crawl that was aborted.
+func (crawler *DomainCrawler) Stats() Stats {
+	stats := crawler.stats.result()
+	if crawler.config.AdaptiveConcurrency {
+		stats.Concurrency = crawler.concurrency.samples()
+	}
+
+	return stats
+}
+
+// WriteSummary writes a human readable summary of the stats.
+func (s Stats) WriteSummary(out io.Writer) error {
+	var summary strings.Builder
+
+	fmt.Fprintf(&summary, "pages fetched:       %d\n", s.PagesFetched)
+	fmt.Fprintf(&summary, "bytes downloaded:    %d\n", s.BytesDownloaded)
+	fmt.Fprintf(&summary, "links discovered:    %d\n", s.LinksDiscovered)
+	fmt.Fprintf(&summary, "duplicates skipped:  %d\n", s.DuplicatesSkipped)
+	fmt.Fprintf(
+		&summary,
+		"dropped:             %d out of scope, %d nofollow, %d queue full\n",
+		s.DroppedOutOfScope,
+		s.DroppedNoFollow,
+		s.DroppedQueueFull,
+	)
+
+	statusCodes := make([]int, 0, len(s.StatusCodes))
+	for statusCode := range s.StatusCodes {
+		statusCodes = append(statusCodes, statusCode)
+	}
+	sort.Ints(statusCodes)
+
+	counts := make([]string, 0, len(statusCodes))
+	for _, statusCode := range statusCodes {
+		counts = append(
+			counts,
+			fmt.Sprintf("%d: %d", statusCode, s.StatusCodes[statusCode]),
+		)
+	}
+	fmt.Fprintf(&summary, "status codes:        %s\n", joinOrNone(counts))
+
+	errorTypes := make([]string, 0, len(s.Errors))
+	for errorType := range s.Errors {
+		errorTypes = append(errorTypes, errorType)
+	}
+	sort.Strings(errorTypes)
+
+	counts = counts[:0]
+	for _, errorType := range errorTypes {
+		counts = append(
+			counts,
+			fmt.Sprintf("%s: %d", errorType, s.Errors[errorType]),
+		)
+	}
+	fmt.Fprintf(&summary, "errors:              %s\n", joinOrNone(counts))
+
+	fmt.Fprintf(
+		&summary,
+		"latency:             p50 %s, p90 %s, p99 %s, max %s\n",
+		s.Latency.P50,
+		s.Latency.P90,
+		s.Latency.P99,
+		s.Latency.Max,
+	)
+
+	if len(s.Concurrency) > 0 {
+		levels := make([]string, 0, len(s.Concurrency))
+		for _, sample := range s.Concurrency {
+			levels = append(levels, fmt.Sprint(sample.Concurrency))
+		}
+		fmt.Fprintf(
+			&summary,
+			"concurrency:         %s\n",
+			strings.Join(levels, " -> "),
+		)
+	}
+
+	_, err := io.WriteString(out, summary.String())
+	return err
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// copy returns a copy of the stats that doesn't share maps or slices.
+func (s Stats) copy() Stats {
+	statusCodes := make(map[int]int, len(s.StatusCodes))
+	for statusCode, count := range s.StatusCodes {
+		statusCodes[statusCode] = count
+	}
+
+	errorTypes := make(map[string]int, len(s.Errors))
+	for errorType, count := range s.Errors {
+		errorTypes[errorType] = count
+	}
+
+	s.StatusCodes = statusCodes
+	s.Errors = errorTypes
+	s.Concurrency = append([]ConcurrencySample(nil), s.Concurrency...)
+
+	return s
+}
+
+// crawlStats collects the stats of a running crawl.
+type crawlStats struct {
+	lock      sync.Mutex
+	stats     Stats
+	latencies []time.Duration
+}
+
+func newCrawlStats() *crawlStats {
+	return &crawlStats{
+		stats: Stats{
+			StatusCodes: map[int]int{},
+			Errors:      map[string]int{},
+		},
+	}
+}
+
+// recordPage records the outcome of reading a page. The error type is empty
+// when the page was read successfully.
+func (c *crawlStats) recordPage(
+	linkReader *LinkReader,
+	duration time.Duration,
+	errorType string,
+) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if statusCode := linkReader.StatusCode(); statusCode != 0 {
+		c.stats.PagesFetched++
+		c.stats.BytesDownloaded += linkReader.Size()
+		c.stats.StatusCodes[statusCode]++
+		c.latencies = append(c.latencies, duration)
+	}
+
+	c.stats.LinksDiscovered += linkReader.noFollowSkipped
+	c.stats.DroppedNoFollow += linkReader.noFollowSkipped
+
+	if errorType != "" {
+		c.stats.Errors[errorType]++
+	}
+}
+
+// recordLink records a link read from a page.
+func (c *crawlStats) recordLink() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stats.LinksDiscovered++
+}
+
+// recordDuplicate records a link to a URL already in the site map.
+func (c *crawlStats) recordDuplicate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stats.DuplicatesSkipped++
+}
+
+// recordOutOfScope records a link that was not crawled because it is outside
+// the domain of the roots.
+func (c *crawlStats) recordOutOfScope() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stats.DroppedOutOfScope++
+}
+
+// recordQueueFull records a new URL that was dropped because the pending URL
+// queue was full.
+func (c *crawlStats) recordQueueFull() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stats.DroppedQueueFull++
+}
+
+// pagesFetched returns the number of requests that got a response.
+func (c *crawlStats) pagesFetched() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.stats.PagesFetched
+}
+
+// result returns the stats collected so far.
+func (c *crawlStats) result() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stats := c.stats.copy()
+	stats.Latency = latencyPercentiles(c.latencies)
+
+	return stats
+}
+
+// latencyPercentiles computes the nearest-rank percentiles of the latencies.
+func latencyPercentiles(latencies []time.Duration) LatencyPercentiles {
+	if len(latencies) == 0 {
+		return LatencyPercentiles{}
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	percentile := func(p float64) time.Duration {
+		rank := int(math.Ceil(p * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+
+	return LatencyPercentiles{
+		P50: percentile(0.5),
+		P90: percentile(0.9),
+		P99: percentile(0.99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+// errorType returns the type of the error that stopped the page from being
+// read, or an empty string when there was no error.
+func (crawler *DomainCrawler) errorType(
+	linkReader *LinkReader,
+	err error,
+) string {
+	if err == nil {
+		return ""
+	}
+
+	if err == errSessionExpired {
+		return ErrorTypeSessionExpired
+	}
+
+	// The page is only left on the expired session redirect when logging in
+	// again failed.
+	if crawler.isSessionExpired(linkReader) {
+		return ErrorTypeLogin
+	}
+
+	if requestErr := linkReader.requestErr; requestErr != nil {
+		var blocked *BlockedAddressError
+		if errors.As(requestErr, &blocked) {
+			return ErrorTypeBlocked
+		}
+
+		var netErr net.Error
+		if errors.As(requestErr, &netErr) && netErr.Timeout() {
+			return ErrorTypeTimeout
+		}
+
+		return ErrorTypeRequest
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorTypeTimeout
+	}
+
+	return ErrorTypeRead
+}
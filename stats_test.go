@@ -0,0 +1,244 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCrawlStats(t *testing.T) {
+	pages := map[string]string{
+		"/": `<a href="/a">a</a><a href="/a">a</a><a href="/missing">b</a>` +
+			`<a href="http://external.com/">x</a>` +
+			`<a href="/private" rel="nofollow">c</a>`,
+		"/a": `<p>page a</p>`,
+	}
+
+	testServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, ok := pages[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, body)
+		},
+	))
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(testServer.URL, SetLogger(zap.NewNop()))
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	stats := sitemap.Stats()
+
+	expectedBytes := int64(len(pages["/"]) + len(pages["/a"]) +
+		len("404 page not found\n"))
+	if stats.PagesFetched != 3 || stats.BytesDownloaded != expectedBytes {
+		t.Errorf(
+			"expected 3 pages and %d bytes but got %d pages and %d bytes",
+			expectedBytes,
+			stats.PagesFetched,
+			stats.BytesDownloaded,
+		)
+	}
+
+	links := []int{
+		stats.LinksDiscovered,
+		stats.DuplicatesSkipped,
+		stats.DroppedOutOfScope,
+		stats.DroppedNoFollow,
+		stats.DroppedQueueFull,
+	}
+	if expected := []int{5, 1, 1, 1, 0}; !reflect.DeepEqual(links, expected) {
+		t.Errorf(
+			"expected discovered, duplicate, out of scope, nofollow and "+
+				"queue full links %v but got %v",
+			expected,
+			links,
+		)
+	}
+
+	expectedStatusCodes := map[int]int{200: 2, 404: 1}
+	if !reflect.DeepEqual(stats.StatusCodes, expectedStatusCodes) {
+		t.Errorf(
+			"expected status codes %v but got %v",
+			expectedStatusCodes,
+			stats.StatusCodes,
+		)
+	}
+
+	if len(stats.Errors) != 0 {
+		t.Errorf("expected no errors but got %v", stats.Errors)
+	}
+
+	if stats.Latency.Max <= 0 || stats.Latency.P50 > stats.Latency.Max {
+		t.Errorf("expected latency percentiles but got %+v", stats.Latency)
+	}
+}
+
+func TestCrawlStatsQueueFull(t *testing.T) {
+	testServer := newTestServer()
+	defer testServer.Close()
+
+	sitemap, err := CrawlDomain(
+		testServer.URL,
+		SetMaxConcurrency(1),
+		SetMaxPendingURLS(1),
+		SetClient(testServer.Client()),
+		SetLogger(zap.NewNop()),
+	)
+	if err != nil {
+		t.Fatalf("error crawling site: %q", err)
+	}
+
+	if stats := sitemap.Stats(); stats.DroppedQueueFull == 0 {
+		t.Errorf("expected urls to be dropped when the queue is full")
+	}
+}
+
+func TestCrawlStatsErrorTypes(t *testing.T) {
+	slowServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		},
+	))
+	defer slowServer.Close()
+
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	tests := []struct {
+		url       string
+		opts      []Option
+		errorType string
+	}{
+		{
+			slowServer.URL,
+			[]Option{SetTimeout(20 * time.Millisecond)},
+			ErrorTypeTimeout,
+		},
+		{
+			closedServer.URL,
+			nil,
+			ErrorTypeRequest,
+		},
+		{
+			slowServer.URL,
+			[]Option{SetDeniedNetworks(PrivateNetworks()...)},
+			ErrorTypeBlocked,
+		},
+	}
+
+	for _, test := range tests {
+		root, _ := url.Parse(test.url)
+		config := NewConfig(append(test.opts, SetLogger(zap.NewNop()))...)
+
+		crawler, err := NewDomainCrawler(root, config)
+		if err != nil {
+			t.Fatalf("error creating crawler: %q", err)
+		}
+
+		if _, err := crawler.Crawl(); err == nil {
+			t.Errorf("expected crawl of %s to fail", test.url)
+		}
+
+		expected := map[string]int{test.errorType: 1}
+		if errors := crawler.Stats().Errors; !reflect.DeepEqual(errors, expected) {
+			t.Errorf("expected errors %v but got %v", expected, errors)
+		}
+	}
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	expected := LatencyPercentiles{
+		P50: 50 * time.Millisecond,
+		P90: 90 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+		Max: 100 * time.Millisecond,
+	}
+	if actual := latencyPercentiles(latencies); actual != expected {
+		t.Errorf("expected percentiles %+v but got %+v", expected, actual)
+	}
+
+	if actual := latencyPercentiles(nil); actual != (LatencyPercentiles{}) {
+		t.Errorf("expected no percentiles without latencies but got %+v", actual)
+	}
+}
+
+func TestStatsWriteSummary(t *testing.T) {
+	stats := Stats{
+		PagesFetched:      3,
+		BytesDownloaded:   1024,
+		LinksDiscovered:   10,
+		DuplicatesSkipped: 4,
+		DroppedOutOfScope: 2,
+		DroppedNoFollow:   1,
+		StatusCodes:       map[int]int{404: 1, 200: 2},
+		Errors:            map[string]int{ErrorTypeTimeout: 1},
+		Latency: LatencyPercentiles{
+			P50: 10 * time.Millisecond,
+			P90: 20 * time.Millisecond,
+			P99: 30 * time.Millisecond,
+			Max: 40 * time.Millisecond,
+		},
+		Concurrency: []ConcurrencySample{
+			{Concurrency: 1},
+			{Concurrency: 2},
+		},
+	}
+
+	expected := `pages fetched:       3
+bytes downloaded:    1024
+links discovered:    10
+duplicates skipped:  4
+dropped:             2 out of scope, 1 nofollow, 0 queue full
+status codes:        200: 2, 404: 1
+errors:              timeout: 1
+latency:             p50 10ms, p90 20ms, p99 30ms, max 40ms
+concurrency:         1 -> 2
+`
+
+	var summary bytes.Buffer
+	if err := stats.WriteSummary(&summary); err != nil {
+		t.Fatalf("error writing summary: %q", err)
+	}
+
+	if summary.String() != expected {
+		t.Errorf("expected summary:\n%s\nbut got:\n%s", expected, summary.String())
+	}
+}
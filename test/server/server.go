@@ -22,11 +22,14 @@ package server
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -148,3 +151,114 @@ func DirectoryHandler(dir string) (http.HandlerFunc, error) {
 		}
 	}, nil
 }
+
+// LoginHandler protects a handler with a form login. A POST to the login path
+// with the expected username and password starts a session, kept in a cookie,
+// that expires after a number of requests. Requests without a valid session
+// are redirected to the login path.
+type LoginHandler struct {
+	handler     http.Handler
+	loginPath   string
+	username    string
+	password    string
+	maxRequests int
+
+	lock     sync.Mutex
+	sessions map[string]int
+	logins   int
+}
+
+// sessionCookie is the name of the cookie that holds the login session.
+const sessionCookie = "session"
+
+// NewLoginHandler returns a handler that serves the wrapped handler to logged
+// in clients. Sessions expire after maxRequests requests, or never when
+// maxRequests is 0.
+func NewLoginHandler(
+	handler http.Handler,
+	loginPath string,
+	username string,
+	password string,
+	maxRequests int,
+) *LoginHandler {
+	return &LoginHandler{
+		handler:     handler,
+		loginPath:   loginPath,
+		username:    username,
+		password:    password,
+		maxRequests: maxRequests,
+		sessions:    map[string]int{},
+	}
+}
+
+// ServeHTTP handles login requests and serves the wrapped handler to clients
+// with a valid session.
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == h.loginPath {
+		h.handleLogin(w, r)
+		return
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || !h.useSession(cookie.Value) {
+		http.Redirect(w, r, h.loginPath, http.StatusFound)
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
+// Logins returns the number of successful logins.
+func (h *LoginHandler) Logins() int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.logins
+}
+
+func (h *LoginHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<form method="post">`+
+			`<input name="username"><input name="password" type="password">`+
+			`</form>`)
+		return
+	}
+
+	if r.PostFormValue("username") != h.username ||
+		r.PostFormValue("password") != h.password {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	h.lock.Lock()
+	h.logins++
+	session := strconv.Itoa(h.logins)
+	h.sessions[session] = h.maxRequests
+	h.lock.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: session, Path: "/"})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// useSession returns true if the session is valid and counts the request
+// against it.
+func (h *LoginHandler) useSession(session string) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	remaining, ok := h.sessions[session]
+	if !ok {
+		return false
+	}
+
+	if h.maxRequests > 0 {
+		if remaining == 0 {
+			delete(h.sessions, session)
+			return false
+		}
+		h.sessions[session] = remaining - 1
+	}
+
+	return true
+}
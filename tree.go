@@ -0,0 +1,188 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TreeOptions configures the output of WriteTree.
+type TreeOptions struct {
+	// Counts appends the number of pages below each node with children.
+	Counts bool
+
+	// Status annotates each page with the status code of its response.
+	Status bool
+}
+
+// WriteTree writes the site map to the writer as an indented tree, in the
+// style of tree(1). URLs are grouped by origin and path segments, and query
+// variants are listed below the page they belong to.
+func (s *SiteMap) WriteTree(out io.Writer, options TreeOptions) error {
+	for _, node := range buildSiteTree(s.Pages()) {
+		if _, err := io.WriteString(out, node.label(options)+"\n"); err != nil {
+			return err
+		}
+
+		if err := node.writeChildren(out, "", options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeChildren writes the children of the node with the given prefix, using
+// box drawing characters to connect each child to its parent.
+func (n *siteTreeNode) writeChildren(
+	out io.Writer,
+	prefix string,
+	options TreeOptions,
+) error {
+	for i, child := range n.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		_, err := io.WriteString(out, prefix+branch+child.label(options)+"\n")
+		if err != nil {
+			return err
+		}
+
+		if err := child.writeChildren(out, prefix+indent, options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// label formats the name of the node with the optional annotations.
+func (n *siteTreeNode) label(options TreeOptions) string {
+	label := n.Name
+
+	if options.Status && len(n.Pages) > 0 {
+		statuses := make([]string, len(n.Pages))
+		for i, page := range n.Pages {
+			statuses[i] = statusString(page.StatusCode)
+		}
+		label += " [" + strings.Join(statuses, ", ") + "]"
+	}
+
+	if options.Counts && len(n.Children) > 0 {
+		label += fmt.Sprintf(" (%d)", n.Count)
+	}
+
+	return label
+}
+
+// siteTreeNode is a node in the hierarchy of hosts and path segments of the
+// pages in a site map. Pages whose URL ends at the node are kept on the node
+// and Count is the number of pages in the subtree including the node itself.
+type siteTreeNode struct {
+	Name     string
+	Pages    []Page
+	Children []*siteTreeNode
+	Count    int
+
+	children map[string]*siteTreeNode
+}
+
+// buildSiteTree groups pages by origin and path segments. One node is returned
+// for each origin, ordered by name. A query string is treated as a final path
+// segment so that query variants appear below the page they belong to.
+func buildSiteTree(pages []Page) []*siteTreeNode {
+	root := newSiteTreeNode("")
+
+	for _, page := range pages {
+		node := root
+		for _, segment := range siteTreeSegments(page.URL) {
+			node = node.child(segment)
+		}
+		node.Pages = append(node.Pages, page)
+	}
+
+	root.finalize()
+	return root.Children
+}
+
+func newSiteTreeNode(name string) *siteTreeNode {
+	return &siteTreeNode{
+		Name:     name,
+		children: map[string]*siteTreeNode{},
+	}
+}
+
+// child returns the named child node, creating it if necessary.
+func (n *siteTreeNode) child(name string) *siteTreeNode {
+	child := n.children[name]
+	if child == nil {
+		child = newSiteTreeNode(name)
+		n.children[name] = child
+	}
+	return child
+}
+
+// finalize orders the children by name and counts the pages in the subtree.
+func (n *siteTreeNode) finalize() int {
+	n.Children = make([]*siteTreeNode, 0, len(n.children))
+	for _, child := range n.children {
+		n.Children = append(n.Children, child)
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+
+	n.Count = len(n.Pages)
+	for _, child := range n.Children {
+		n.Count += child.finalize()
+	}
+	n.children = nil
+
+	return n.Count
+}
+
+// siteTreeSegments splits a URL into its origin, path segments and query.
+// URLs which can't be parsed are kept as a single segment.
+func siteTreeSegments(rawURL string) []string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return []string{rawURL}
+	}
+
+	segments := []string{parsed.Scheme + "://" + parsed.Host}
+	for _, segment := range strings.Split(parsed.EscapedPath(), "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	if parsed.RawQuery != "" {
+		segments = append(segments, "?"+parsed.RawQuery)
+	}
+
+	return segments
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildSiteTree(t *testing.T) {
+	pages := []Page{
+		{URL: "http://a.com"},
+		{URL: "http://a.com/"},
+		{URL: "http://a.com/docs/intro"},
+		{URL: "http://a.com/docs/intro?lang=fr"},
+		{URL: "http://a.com/docs/setup/"},
+		{URL: "https://b.com/about"},
+	}
+
+	tree := buildSiteTree(pages)
+
+	var lines []string
+	var walk func(nodes []*siteTreeNode, indent string)
+	walk = func(nodes []*siteTreeNode, indent string) {
+		for _, node := range nodes {
+			lines = append(lines, fmt.Sprintf(
+				"%s%s %d/%d",
+				indent,
+				node.Name,
+				len(node.Pages),
+				node.Count,
+			))
+			walk(node.Children, indent+"  ")
+		}
+	}
+	walk(tree, "")
+
+	expected := strings.Join([]string{
+		"http://a.com 2/5",
+		"  docs 0/3",
+		"    intro 1/2",
+		"      ?lang=fr 1/1",
+		"    setup 1/1",
+		"https://b.com 0/1",
+		"  about 1/1",
+	}, "\n")
+
+	if strings.Join(lines, "\n") != expected {
+		t.Errorf(
+			"unexpected site tree.\n\nGot:\n\n%s\n\nExpected:\n\n%s",
+			strings.Join(lines, "\n"),
+			expected,
+		)
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://a.com",
+		"urls": [
+			{"url": "http://a.com/", "status": 200},
+			{"url": "http://a.com/docs/intro", "status": 200},
+			{"url": "http://a.com/docs/intro?lang=fr", "status": 301},
+			{"url": "http://a.com/docs/setup/", "status": 404},
+			{"url": "http://a.com/shop"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	var out bytes.Buffer
+	err = sitemap.WriteTree(&out, TreeOptions{Counts: true, Status: true})
+	if err != nil {
+		t.Fatalf("error writing tree: %q", err)
+	}
+
+	expected := "http://a.com [200] (5)\n" +
+		"├── docs (3)\n" +
+		"│   ├── intro [200] (2)\n" +
+		"│   │   └── ?lang=fr [301]\n" +
+		"│   └── setup [404]\n" +
+		"└── shop [none]\n"
+
+	if out.String() != expected {
+		t.Errorf(
+			"unexpected tree.\n\nGot:\n\n%s\n\nExpected:\n\n%s",
+			out.String(),
+			expected,
+		)
+	}
+
+	out.Reset()
+	if err := sitemap.WriteTree(&out, TreeOptions{}); err != nil {
+		t.Fatalf("error writing tree: %q", err)
+	}
+
+	if !strings.HasPrefix(out.String(), "http://a.com\n├── docs\n") {
+		t.Errorf(
+			"expected tree without annotations but got:\n\n%s",
+			out.String(),
+		)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// defaultPorts are the ports implied by URL schemes without an explicit port.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// ValidateOrigin is a domain validation function that compares the origin of
+// the URLs, which is the scheme, host and port. Default ports are treated the
+// same as no port, so http://example.com:80 has the origin of
+// http://example.com.
+func ValidateOrigin(root, link *url.URL) bool {
+	return strings.EqualFold(root.Scheme, link.Scheme) &&
+		strings.EqualFold(root.Hostname(), link.Hostname()) &&
+		urlPort(root) == urlPort(link)
+}
+
+// ValidateHostsIgnoringWWW is a domain validation function that compares the
+// hosts of the URLs like ValidateHosts, but treats a www subdomain as the same
+// host as the domain without it, so www.example.com matches example.com.
+func ValidateHostsIgnoringWWW(root, link *url.URL) bool {
+	rootHost := withoutWWW(root.Hostname())
+	linkHost := withoutWWW(link.Hostname())
+
+	return strings.EqualFold(rootHost, linkHost) && root.Port() == link.Port()
+}
+
+// ValidateSubdomains is a domain validation function that accepts any host
+// under the registrable domain of the root, as found with the public suffix
+// list. For example blog.example.co.uk is accepted for www.example.co.uk, but
+// other.co.uk is not. Hosts without a registrable domain, such as IP addresses
+// and localhost, must match exactly. The scheme and port are ignored.
+func ValidateSubdomains(root, link *url.URL) bool {
+	rootDomain := registrableDomain(root.Hostname())
+	linkDomain := registrableDomain(link.Hostname())
+
+	return rootDomain != "" && rootDomain == linkDomain
+}
+
+// AllowHosts returns a domain validator that accepts links to the hosts,
+// whatever the root. Hosts may include a port to only accept that port.
+func AllowHosts(hosts ...string) DomainValidator {
+	return hostAllowlist(hosts)
+}
+
+type hostAllowlist []string
+
+func (h hostAllowlist) Validate(root, link *url.URL) bool {
+	for _, host := range h {
+		if strings.EqualFold(host, link.Host) ||
+			strings.EqualFold(host, link.Hostname()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h hostAllowlist) String() string {
+	return "hosts(" + strings.Join(h, ", ") + ")"
+}
+
+// And returns a domain validator that accepts links accepted by all of the
+// validators.
+func And(validators ...DomainValidator) DomainValidator {
+	return andValidator(validators)
+}
+
+type andValidator []DomainValidator
+
+func (a andValidator) Validate(root, link *url.URL) bool {
+	for _, validator := range a {
+		if !validator.Validate(root, link) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a andValidator) String() string {
+	return "and(" + describeValidators(a) + ")"
+}
+
+// Or returns a domain validator that accepts links accepted by any of the
+// validators.
+func Or(validators ...DomainValidator) DomainValidator {
+	return orValidator(validators)
+}
+
+type orValidator []DomainValidator
+
+func (o orValidator) Validate(root, link *url.URL) bool {
+	for _, validator := range o {
+		if validator.Validate(root, link) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o orValidator) String() string {
+	return "or(" + describeValidators(o) + ")"
+}
+
+// Not returns a domain validator that accepts links rejected by the validator.
+func Not(validator DomainValidator) DomainValidator {
+	return notValidator{validator}
+}
+
+type notValidator struct {
+	validator DomainValidator
+}
+
+func (n notValidator) Validate(root, link *url.URL) bool {
+	return !n.validator.Validate(root, link)
+}
+
+func (n notValidator) String() string {
+	return "not(" + describeValidator(n.validator) + ")"
+}
+
+func describeValidators(validators []DomainValidator) string {
+	descriptions := make([]string, len(validators))
+	for i, validator := range validators {
+		descriptions[i] = describeValidator(validator)
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+// urlPort returns the port of the URL, or the default port of its scheme.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+
+	return defaultPorts[strings.ToLower(u.Scheme)]
+}
+
+func withoutWWW(host string) string {
+	if len(host) > 4 && strings.EqualFold(host[:4], "www.") {
+		return host[4:]
+	}
+
+	return host
+}
+
+// registrableDomain returns the registrable domain of the host, or the host
+// itself if it has none.
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+
+	return domain
+}
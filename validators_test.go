@@ -0,0 +1,122 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"net/url"
+	"testing"
+)
+
+type validatorTest struct {
+	root     string
+	link     string
+	expected bool
+}
+
+func testValidator(t *testing.T, validator DomainValidator, tests []validatorTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		root, _ := url.Parse(test.root)
+		link, _ := url.Parse(test.link)
+
+		if validator.Validate(root, link) != test.expected {
+			t.Errorf(
+				"expected %s validating %s against %s to be %v",
+				describeValidator(validator),
+				test.link,
+				test.root,
+				test.expected,
+			)
+		}
+	}
+}
+
+func TestValidateOrigin(t *testing.T) {
+	testValidator(t, DomainValidatorFunc(ValidateOrigin), []validatorTest{
+		{"https://example.com", "https://example.com/a", true},
+		{"https://example.com", "https://EXAMPLE.com:443/a", true},
+		{"http://example.com:80", "http://example.com/a", true},
+		{"https://example.com", "http://example.com/a", false},
+		{"https://example.com", "https://example.com:8443/a", false},
+		{"https://example.com", "https://www.example.com/a", false},
+	})
+}
+
+func TestValidateHostsIgnoringWWW(t *testing.T) {
+	testValidator(t, DomainValidatorFunc(ValidateHostsIgnoringWWW), []validatorTest{
+		{"https://example.com", "https://www.example.com/a", true},
+		{"https://www.example.com", "http://example.com/a", true},
+		{"https://WWW.example.com", "https://www.example.com/a", true},
+		{"https://example.com", "https://blog.example.com/a", false},
+		{"https://example.com", "https://example.com:8080/a", false},
+		{"https://www.com", "https://com/a", true},
+	})
+}
+
+func TestValidateSubdomains(t *testing.T) {
+	testValidator(t, DomainValidatorFunc(ValidateSubdomains), []validatorTest{
+		{"https://www.example.co.uk", "https://blog.example.co.uk/a", true},
+		{"https://www.example.co.uk", "http://example.co.uk:8080/a", true},
+		{"https://www.example.co.uk", "https://other.co.uk/a", false},
+		{"https://a.github.io", "https://b.github.io/", false},
+		{"http://127.0.0.1:8080", "http://127.0.0.1/a", true},
+		{"http://127.0.0.1", "http://10.0.0.1/a", false},
+		{"http://localhost", "http://localhost:8080/a", true},
+	})
+}
+
+func TestAllowHosts(t *testing.T) {
+	testValidator(t, AllowHosts("cdn.example.com", "localhost:8080"), []validatorTest{
+		{"https://example.com", "https://cdn.example.com/a", true},
+		{"https://example.com", "http://CDN.example.com:81/a", true},
+		{"https://example.com", "http://localhost:8080/a", true},
+		{"https://example.com", "http://localhost:9090/a", false},
+		{"https://example.com", "https://example.com/a", false},
+	})
+}
+
+func TestValidatorCombinators(t *testing.T) {
+	validator := Or(
+		And(
+			DomainValidatorFunc(ValidateSubdomains),
+			Not(AllowHosts("admin.example.com")),
+		),
+		AllowHosts("cdn.other.com"),
+	)
+
+	testValidator(t, validator, []validatorTest{
+		{"https://example.com", "https://blog.example.com/a", true},
+		{"https://example.com", "https://admin.example.com/a", false},
+		{"https://example.com", "https://cdn.other.com/a", true},
+		{"https://example.com", "https://other.com/a", false},
+	})
+
+	expected := "or(and(github.com/Matt-Esch/sitemapper.ValidateSubdomains, " +
+		"not(hosts(admin.example.com))), hosts(cdn.other.com))"
+	if describeValidator(validator) != expected {
+		t.Errorf(
+			"expected description %q but got %q",
+			expected,
+			describeValidator(validator),
+		)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+)
+
+// sitemapNamespace, imageNamespace and xhtmlNamespace are the XML namespaces
+// of the sitemap protocol, the Google image sitemap extension and the XHTML
+// links used for localized alternates.
+const (
+	sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	imageNamespace   = "http://www.google.com/schemas/sitemap-image/1.1"
+	xhtmlNamespace   = "http://www.w3.org/1999/xhtml"
+)
+
+// xmlURLSet is the root element of an XML sitemap.
+type xmlURLSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Image   string   `xml:"xmlns:image,attr,omitempty"`
+	XHTML   string   `xml:"xmlns:xhtml,attr,omitempty"`
+	URLs    []xmlURL `xml:"url"`
+}
+
+type xmlURL struct {
+	Loc        string     `xml:"loc"`
+	LastMod    string     `xml:"lastmod,omitempty"`
+	ChangeFreq string     `xml:"changefreq,omitempty"`
+	Priority   string     `xml:"priority,omitempty"`
+	Alternates []xmlLink  `xml:"xhtml:link"`
+	Images     []xmlImage `xml:"image:image"`
+}
+
+type xmlLink struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+type xmlImage struct {
+	Loc string `xml:"image:loc"`
+}
+
+// XMLOption configures the output of WriteXML.
+type XMLOption interface {
+	applyXML(options *xmlOptions)
+}
+
+type xmlOptions struct {
+	canonicalOnly     bool
+	priority          priorityMode
+	rules             []SitemapRule
+	maxURLsPerSitemap int
+	baseURL           string
+}
+
+// priorityMode is the page metric used to compute the priority of each page.
+type priorityMode int
+
+const (
+	priorityNone priorityMode = iota
+	priorityDepth
+	priorityInlinks
+)
+
+type xmlOptionFunc func(options *xmlOptions)
+
+func (o xmlOptionFunc) applyXML(options *xmlOptions) {
+	o(options)
+}
+
+// CanonicalOnly leaves pages out of the XML output when they declare a
+// canonical URL other than their own, such as query variants of a page. Pages
+// are only left out when their canonical URL is in the site map with 200 OK,
+// so that URLs don't disappear from the sitemap behind a broken canonical.
+func CanonicalOnly() XMLOption {
+	return xmlOptionFunc(func(options *xmlOptions) {
+		options.canonicalOnly = true
+	})
+}
+
+// PriorityByDepth sets the priority of each page from its click depth. The
+// shallowest pages have a priority of 1.0, which decreases by 0.2 for each
+// level down to a minimum of 0.1.
+func PriorityByDepth() XMLOption {
+	return xmlOptionFunc(func(options *xmlOptions) {
+		options.priority = priorityDepth
+	})
+}
+
+// PriorityByInlinks sets the priority of each page from the number of pages
+// that link to it, relative to the most linked page, between 0.1 and 1.0.
+func PriorityByInlinks() XMLOption {
+	return xmlOptionFunc(func(options *xmlOptions) {
+		options.priority = priorityInlinks
+	})
+}
+
+// ApplySitemapRules sets the changefreq and priority of pages from the first
+// rule matching their URL. Rules take precedence over computed priorities.
+func ApplySitemapRules(rules []SitemapRule) XMLOption {
+	return xmlOptionFunc(func(options *xmlOptions) {
+		options.rules = rules
+	})
+}
+
+// WriteXML writes the site map to the writer in the sitemap XML format.
+// Pages that are known to be redirects, errors or otherwise unsuccessful are
+// left out, as are pages that ask not to be indexed unless the crawl was
+// configured to ignore noindex directives. The last modified time of each page
+// is written as lastmod, the images of each page are written with the Google
+// image sitemap extension and localized alternates are written as xhtml:link
+// elements.
+func (s *SiteMap) WriteXML(out io.Writer, opts ...XMLOption) error {
+	options := newXMLOptions(opts)
+	return writeXMLDocument(out, newURLSet(s.xmlEntries(options)))
+}
+
+// newXMLOptions applies the options to the default XML options.
+func newXMLOptions(opts []XMLOption) *xmlOptions {
+	options := &xmlOptions{maxURLsPerSitemap: MaxSitemapURLs}
+	for _, opt := range opts {
+		opt.applyXML(options)
+	}
+
+	return options
+}
+
+// xmlEntries returns the url entries of the pages to write to the sitemap.
+func (s *SiteMap) xmlEntries(options *xmlOptions) []xmlURL {
+	allPages := s.Pages()
+
+	statusCodes := make(map[string]int, len(allPages))
+	for _, page := range allPages {
+		statusCodes[page.URL] = page.StatusCode
+	}
+
+	var pages []Page
+	for _, page := range allPages {
+		if !isSitemapPage(&page) || s.isExcludedNoIndex(&page) {
+			continue
+		}
+
+		if options.canonicalOnly && !isCanonicalPage(&page) &&
+			statusCodes[page.Canonical] == http.StatusOK {
+			continue
+		}
+
+		pages = append(pages, page)
+	}
+
+	priorities := pagePriorities(pages, options.priority)
+	entries := make([]xmlURL, 0, len(pages))
+
+	for i, page := range pages {
+		entry := xmlURL{Loc: page.URL}
+		if page.LastModified != nil {
+			entry.LastMod = page.LastModified.UTC().Format(time.RFC3339)
+		}
+
+		if priorities[i] > 0 {
+			entry.Priority = fmt.Sprintf("%.1f", priorities[i])
+		}
+		if rule := matchSitemapRule(options.rules, page.URL); rule != nil {
+			entry.ChangeFreq = rule.ChangeFreq
+			if rule.Priority != nil {
+				entry.Priority = fmt.Sprintf("%.1f", *rule.Priority)
+			}
+		}
+
+		for _, image := range page.Images {
+			entry.Images = append(entry.Images, xmlImage{Loc: image})
+		}
+
+		for _, alternate := range page.Alternates {
+			entry.Alternates = append(entry.Alternates, xmlLink{
+				Rel:      "alternate",
+				Hreflang: alternate.Hreflang,
+				Href:     alternate.URL,
+			})
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// newURLSet returns a url set of the entries, declaring the image and xhtml
+// namespaces only when they are used.
+func newURLSet(entries []xmlURL) xmlURLSet {
+	urlSet := xmlURLSet{XMLNS: sitemapNamespace, URLs: entries}
+
+	for _, entry := range entries {
+		if len(entry.Images) > 0 {
+			urlSet.Image = imageNamespace
+		}
+		if len(entry.Alternates) > 0 {
+			urlSet.XHTML = xhtmlNamespace
+		}
+	}
+
+	return urlSet
+}
+
+// writeXMLDocument writes the XML header followed by the indented document.
+func writeXMLDocument(out io.Writer, document interface{}) error {
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// pagePriorities computes the priority of each page with the given metric. A
+// priority of 0 means that the page has no priority.
+func pagePriorities(pages []Page, mode priorityMode) []float64 {
+	priorities := make([]float64, len(pages))
+
+	switch mode {
+	case priorityDepth:
+		minDepth := 0
+		for i, page := range pages {
+			if i == 0 || page.Depth < minDepth {
+				minDepth = page.Depth
+			}
+		}
+		for i, page := range pages {
+			priority := 1.0 - 0.2*float64(page.Depth-minDepth)
+			priorities[i] = math.Max(priority, 0.1)
+		}
+	case priorityInlinks:
+		maxInlinks := 0
+		for _, page := range pages {
+			if len(page.Inlinks) > maxInlinks {
+				maxInlinks = len(page.Inlinks)
+			}
+		}
+		for i, page := range pages {
+			priority := 0.1
+			if maxInlinks > 0 {
+				priority += 0.9 * float64(len(page.Inlinks)) / float64(maxInlinks)
+			}
+			priorities[i] = math.Round(priority*10) / 10
+		}
+	}
+
+	return priorities
+}
+
+// isSitemapPage returns false for pages that should not be listed in a
+// sitemap because they were not successfully fetched. Pages that were never
+// fetched are listed, as nothing is known about them.
+func isSitemapPage(page *Page) bool {
+	if page.Error != "" {
+		return false
+	}
+
+	return page.StatusCode == 0 ||
+		(page.StatusCode >= 200 && page.StatusCode < 300)
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteXML(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/", "status": 200,
+				"images": ["http://example.com/a.jpg", "http://cdn.com/b&c.png"],
+				"alternates": [
+					{"hreflang": "en", "url": "http://example.com/"},
+					{"hreflang": "fr", "url": "http://example.com/fr/"}
+				]},
+			{"url": "http://example.com/moved", "status": 301},
+			{"url": "http://example.com/missing", "status": 404},
+			{"url": "http://example.com/broken", "error": "http get error"},
+			{"url": "http://example.com/unknown"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	var out bytes.Buffer
+	if err := sitemap.WriteXML(&out); err != nil {
+		t.Fatalf("error writing xml: %q", err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" ` +
+		`xmlns:image="http://www.google.com/schemas/sitemap-image/1.1" ` +
+		`xmlns:xhtml="http://www.w3.org/1999/xhtml">
+  <url>
+    <loc>http://example.com/</loc>
+    <xhtml:link rel="alternate" hreflang="en" href="http://example.com/">` +
+		`</xhtml:link>
+    <xhtml:link rel="alternate" hreflang="fr" href="http://example.com/fr/">` +
+		`</xhtml:link>
+    <image:image>
+      <image:loc>http://example.com/a.jpg</image:loc>
+    </image:image>
+    <image:image>
+      <image:loc>http://cdn.com/b&amp;c.png</image:loc>
+    </image:image>
+  </url>
+  <url>
+    <loc>http://example.com/unknown</loc>
+  </url>
+</urlset>
+`
+
+	if out.String() != expected {
+		t.Errorf(
+			"unexpected xml.\n\nGot:\n\n%s\n\nExpected:\n\n%s",
+			out.String(),
+			expected,
+		)
+	}
+}
+
+func TestWriteXMLMetadata(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(`{
+		"version": 1,
+		"root": "http://example.com",
+		"urls": [
+			{"url": "http://example.com/", "status": 200, "depth": 1,
+				"lastModified": "2020-05-01T10:00:00+02:00"},
+			{"url": "http://example.com/a", "status": 200, "depth": 2,
+				"referrer": "http://example.com/"},
+			{"url": "http://example.com/a/b", "status": 200, "depth": 3,
+				"referrer": "http://example.com/a"},
+			{"url": "http://example.com/blog/post", "status": 200, "depth": 2,
+				"referrer": "http://example.com/"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	rules, err := ReadSitemapRules(strings.NewReader(
+		"/blog/ daily 0.9\n/a/b - 0.0\n/a weekly -\n",
+	))
+	if err != nil {
+		t.Fatalf("error reading rules: %q", err)
+	}
+
+	var out bytes.Buffer
+	err = sitemap.WriteXML(&out, PriorityByDepth(), ApplySitemapRules(rules))
+	if err != nil {
+		t.Fatalf("error writing xml: %q", err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>http://example.com/</loc>
+    <lastmod>2020-05-01T08:00:00Z</lastmod>
+    <priority>1.0</priority>
+  </url>
+  <url>
+    <loc>http://example.com/a</loc>
+    <changefreq>weekly</changefreq>
+    <priority>0.8</priority>
+  </url>
+  <url>
+    <loc>http://example.com/a/b</loc>
+    <priority>0.0</priority>
+  </url>
+  <url>
+    <loc>http://example.com/blog/post</loc>
+    <changefreq>daily</changefreq>
+    <priority>0.9</priority>
+  </url>
+</urlset>
+`
+
+	if out.String() != expected {
+		t.Errorf(
+			"unexpected xml.\n\nGot:\n\n%s\n\nExpected:\n\n%s",
+			out.String(),
+			expected,
+		)
+	}
+}
+
+func TestPriorityByInlinks(t *testing.T) {
+	pages := []Page{
+		{URL: "http://example.com/a", Inlinks: []string{"1", "2", "3", "4"}},
+		{URL: "http://example.com/b", Inlinks: []string{"1", "2"}},
+		{URL: "http://example.com/c"},
+	}
+
+	priorities := pagePriorities(pages, priorityInlinks)
+
+	expected := []float64{1.0, 0.6, 0.1}
+	for i := range expected {
+		if priorities[i] != expected[i] {
+			t.Errorf(
+				"expected priority %v for %s but got %v",
+				expected[i],
+				pages[i].URL,
+				priorities[i],
+			)
+		}
+	}
+}
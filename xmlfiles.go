@@ -0,0 +1,169 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxSitemapURLs is the maximum number of URLs allowed in a single sitemap
+// file by the sitemap protocol.
+const MaxSitemapURLs = 50000
+
+// xmlSitemapIndex is the root element of a sitemap index.
+type xmlSitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []xmlSitemap `xml:"sitemap"`
+}
+
+type xmlSitemap struct {
+	Loc string `xml:"loc"`
+}
+
+// SplitSitemapsAt sets the maximum number of URLs written to each sitemap file
+// by WriteXMLFiles. It defaults to MaxSitemapURLs.
+func SplitSitemapsAt(maxURLs int) XMLOption {
+	return xmlOptionFunc(func(options *xmlOptions) {
+		options.maxURLsPerSitemap = maxURLs
+	})
+}
+
+// SitemapBaseURL sets the URL of the directory that the files written by
+// WriteXMLFiles are published under, used for the locations in the sitemap
+// index. It defaults to the root of the site.
+func SitemapBaseURL(baseURL string) XMLOption {
+	return xmlOptionFunc(func(options *xmlOptions) {
+		options.baseURL = baseURL
+	})
+}
+
+// WriteXMLFiles writes the site map to the named file in the sitemap XML
+// format and returns the names of the files written. When there are more URLs
+// than fit in a single sitemap, the URLs are split across numbered sitemaps
+// next to the named file, such as sitemap-1.xml, and the named file is
+// written as a sitemap index that lists them. Files whose name ends in .gz are
+// gzip compressed, and so are the numbered sitemaps listed in their index.
+func (s *SiteMap) WriteXMLFiles(fileName string, opts ...XMLOption) ([]string, error) {
+	options := newXMLOptions(opts)
+	if options.maxURLsPerSitemap <= 0 {
+		return nil, fmt.Errorf(
+			"sitemaps must be split at a positive number of urls but got %d",
+			options.maxURLsPerSitemap,
+		)
+	}
+
+	entries := s.xmlEntries(options)
+	if len(entries) <= options.maxURLsPerSitemap {
+		err := writeXMLFile(fileName, newURLSet(entries))
+		if err != nil {
+			return nil, err
+		}
+		return []string{fileName}, nil
+	}
+
+	baseURL := options.baseURL
+	if baseURL == "" {
+		baseURL = s.url.ResolveReference(&url.URL{Path: "/"}).String()
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	index := xmlSitemapIndex{XMLNS: sitemapNamespace}
+	var fileNames []string
+
+	for i := 0; i*options.maxURLsPerSitemap < len(entries); i++ {
+		start := i * options.maxURLsPerSitemap
+		end := start + options.maxURLsPerSitemap
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		partName := numberedFileName(fileName, i+1)
+		if err := writeXMLFile(partName, newURLSet(entries[start:end])); err != nil {
+			return fileNames, err
+		}
+
+		fileNames = append(fileNames, partName)
+		index.Sitemaps = append(index.Sitemaps, xmlSitemap{
+			Loc: baseURL + filepath.Base(partName),
+		})
+	}
+
+	if err := writeXMLFile(fileName, index); err != nil {
+		return fileNames, err
+	}
+
+	return append(fileNames, fileName), nil
+}
+
+// numberedFileName inserts the number before the extensions of the file name,
+// so that sitemap.xml.gz becomes sitemap-1.xml.gz.
+func numberedFileName(fileName string, number int) string {
+	gzipExt := ""
+	if strings.HasSuffix(fileName, ".gz") {
+		gzipExt = ".gz"
+		fileName = strings.TrimSuffix(fileName, gzipExt)
+	}
+
+	ext := filepath.Ext(fileName)
+	base := strings.TrimSuffix(fileName, ext)
+
+	return fmt.Sprintf("%s-%d%s%s", base, number, ext, gzipExt)
+}
+
+// writeXMLFile writes the XML document to the named file, gzip compressing it
+// when the name ends in .gz.
+func writeXMLFile(fileName string, document interface{}) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = file
+	var gzipWriter *gzip.Writer
+	if strings.HasSuffix(fileName, ".gz") {
+		gzipWriter = gzip.NewWriter(file)
+		out = gzipWriter
+	}
+
+	if err := writeXMLDocument(out, document); err != nil {
+		file.Close()
+		return err
+	}
+
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	return file.Close()
+}
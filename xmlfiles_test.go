@@ -0,0 +1,188 @@
+// Copyright (c) 2020 Matthew Esch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sitemapper
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const xmlFilesSnapshot = `{
+	"version": 1,
+	"root": "http://example.com",
+	"urls": [
+		{"url": "http://example.com/a", "status": 200},
+		{"url": "http://example.com/b", "status": 200},
+		{"url": "http://example.com/c", "status": 200}
+	]
+}`
+
+func TestWriteXMLFilesSingle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitemapper")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sitemap, err := ReadSiteMap(strings.NewReader(xmlFilesSnapshot))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	fileName := filepath.Join(dir, "sitemap.xml")
+	fileNames, err := sitemap.WriteXMLFiles(fileName)
+	if err != nil {
+		t.Fatalf("error writing xml files: %q", err)
+	}
+
+	if !reflect.DeepEqual(fileNames, []string{fileName}) {
+		t.Errorf("expected a single sitemap file but got %v", fileNames)
+	}
+
+	content := readXMLFile(t, fileName)
+	if !strings.Contains(content, "<urlset") ||
+		!strings.Contains(content, "<loc>http://example.com/c</loc>") {
+		t.Errorf("expected a complete url set but got:\n%s", content)
+	}
+}
+
+func TestWriteXMLFilesGzipIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitemapper")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sitemap, err := ReadSiteMap(strings.NewReader(xmlFilesSnapshot))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	fileName := filepath.Join(dir, "sitemap.xml.gz")
+	fileNames, err := sitemap.WriteXMLFiles(fileName, SplitSitemapsAt(2))
+	if err != nil {
+		t.Fatalf("error writing xml files: %q", err)
+	}
+
+	expectedFiles := []string{
+		filepath.Join(dir, "sitemap-1.xml.gz"),
+		filepath.Join(dir, "sitemap-2.xml.gz"),
+		fileName,
+	}
+	if !reflect.DeepEqual(fileNames, expectedFiles) {
+		t.Errorf("expected files %v but got %v", expectedFiles, fileNames)
+	}
+
+	expectedIndex := `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>http://example.com/sitemap-1.xml.gz</loc>
+  </sitemap>
+  <sitemap>
+    <loc>http://example.com/sitemap-2.xml.gz</loc>
+  </sitemap>
+</sitemapindex>
+`
+	if index := readXMLFile(t, fileName); index != expectedIndex {
+		t.Errorf(
+			"unexpected sitemap index.\n\nGot:\n\n%s\n\nExpected:\n\n%s",
+			index,
+			expectedIndex,
+		)
+	}
+
+	second := readXMLFile(t, expectedFiles[1])
+	if strings.Count(second, "<url>") != 1 ||
+		!strings.Contains(second, "<loc>http://example.com/c</loc>") {
+		t.Errorf("expected the last url in the second sitemap:\n%s", second)
+	}
+}
+
+func TestWriteXMLFilesBaseURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitemapper")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sitemap, err := ReadSiteMap(strings.NewReader(xmlFilesSnapshot))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	fileName := filepath.Join(dir, "sitemap.xml")
+	_, err = sitemap.WriteXMLFiles(
+		fileName,
+		SplitSitemapsAt(2),
+		SitemapBaseURL("https://cdn.example.com/maps"),
+	)
+	if err != nil {
+		t.Fatalf("error writing xml files: %q", err)
+	}
+
+	index := readXMLFile(t, fileName)
+	expectedLoc := "<loc>https://cdn.example.com/maps/sitemap-1.xml</loc>"
+	if !strings.Contains(index, expectedLoc) {
+		t.Errorf("expected %s in sitemap index:\n%s", expectedLoc, index)
+	}
+}
+
+func TestWriteXMLFilesInvalidSplit(t *testing.T) {
+	sitemap, err := ReadSiteMap(strings.NewReader(xmlFilesSnapshot))
+	if err != nil {
+		t.Fatalf("error reading snapshot: %q", err)
+	}
+
+	_, err = sitemap.WriteXMLFiles("sitemap.xml", SplitSitemapsAt(0))
+	if err == nil {
+		t.Errorf("expected an error when splitting at 0 urls")
+	}
+}
+
+// readXMLFile reads the content of the file, decompressing .gz files.
+func readXMLFile(t *testing.T, fileName string) string {
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("error opening %s: %q", fileName, err)
+	}
+	defer file.Close()
+
+	var content []byte
+	if strings.HasSuffix(fileName, ".gz") {
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("expected %s to be gzip compressed: %q", fileName, err)
+		}
+		content, err = ioutil.ReadAll(reader)
+	} else {
+		content, err = ioutil.ReadAll(file)
+	}
+	if err != nil {
+		t.Fatalf("error reading %s: %q", fileName, err)
+	}
+
+	return string(content)
+}